@@ -13,13 +13,75 @@ type Config struct {
 	} `toml:"server"`
 
 	Compiler struct {
-		Compiler       string   `toml:"compiler"`
-		CompileFlags   []string `toml:"compile_flags"`
-		CompileTimeout int      `toml:"compile_timeout"`
-		RunTimeout     int      `toml:"run_timeout"`
-		TempDir        string   `toml:"temp_dir"`
+		Compiler         string   `toml:"compiler"`
+		CompileFlags     []string `toml:"compile_flags"`
+		CompileTimeout   int      `toml:"compile_timeout"`
+		RunTimeout       int      `toml:"run_timeout"`
+		TempDir          string   `toml:"temp_dir"`
+		CPUTimeLimit     int      `toml:"cpu_time_limit"`
+		MemoryLimit      int      `toml:"memory_limit"`
+		MaxProcesses     int      `toml:"max_processes"`
+		MaxOpenFiles     int      `toml:"max_open_files"`
+		OutputLimit      int      `toml:"output_limit"`
+		CgroupPath       string   `toml:"cgroup_path"`
+		Isolation        string   `toml:"isolation"`
+		ReadonlyMounts   []string `toml:"readonly_mounts"`
+		StreamInterval   int      `toml:"stream_interval"`
+		StreamLimit      int      `toml:"stream_limit"`
+		SyntaxCheck      bool     `toml:"syntax_check"`
+		SyntaxCheckDelay int      `toml:"syntax_check_delay"`
+		CacheSize        int      `toml:"cache_size"`
+		AllowedFlags     []string `toml:"allowed_flags"`
+		Gcov             string   `toml:"gcov"`
 	} `toml:"compiler"`
 
+	Queue struct {
+		Workers         int `toml:"workers"`
+		MaxPending      int `toml:"max_pending"`
+		MaxConcurrent   int `toml:"max_concurrent"`
+		MaxPerMinute    int `toml:"max_per_minute"`
+		DailyCPUSeconds int `toml:"daily_cpu_seconds"`
+	} `toml:"queue"`
+
+	Stress struct {
+		MaxIterations int `toml:"max_iterations"`
+		TimeLimit     int `toml:"time_limit"`
+	} `toml:"stress"`
+
+	Benchmark struct {
+		MaxRuns   int `toml:"max_runs"`
+		MaxWarmup int `toml:"max_warmup"`
+		TimeLimit int `toml:"time_limit"`
+	} `toml:"benchmark"`
+
+	Debug struct {
+		GDB            string `toml:"gdb"`
+		SessionTimeout int    `toml:"session_timeout"`
+		IdleTimeout    int    `toml:"idle_timeout"`
+		MemoryLimit    int    `toml:"memory_limit"`
+	} `toml:"debug"`
+
+	Fuzz struct {
+		Clang        string `toml:"clang"`
+		ClangC       string `toml:"clang_c"`
+		TimeLimit    int    `toml:"time_limit"`
+		MinimizeTime int    `toml:"minimize_time"`
+		RSSLimit     int    `toml:"rss_limit"`
+		MaxLen       int    `toml:"max_len"`
+	} `toml:"fuzz"`
+
+	Format struct {
+		ClangFormat string `toml:"clang_format"`
+		Style       string `toml:"style"`
+		Timeout     int    `toml:"timeout"`
+	} `toml:"format"`
+
+	Languages []LanguageConfig `toml:"languages"`
+
+	Profiles []ProfileConfig `toml:"profiles"`
+
+	Analyzers []AnalyzerConfig `toml:"analyzers"`
+
 	Auth struct {
 		UsersFile      string `toml:"users_file"`
 		SessionTimeout int    `toml:"session_timeout"`
@@ -32,12 +94,60 @@ type Config struct {
 	} `toml:"websocket"`
 }
 
+// LanguageConfig 编程语言工具链配置
+// 命令中可使用占位符 {src}（源文件）、{exe}（编译产物）、{dir}（运行目录）
+type LanguageConfig struct {
+	Name               string   `toml:"name"`
+	DisplayName        string   `toml:"display_name"`
+	SourceFile         string   `toml:"source_file"`
+	CompileCommand     []string `toml:"compile_command"`
+	DiagnosticsCommand []string `toml:"diagnostics_command"`
+	RunCommand         []string `toml:"run_command"`
+	CompileTimeout     int      `toml:"compile_timeout"`
+	RunTimeout         int      `toml:"run_timeout"`
+	MemoryLimit        int      `toml:"memory_limit"`
+}
+
+// ProfileConfig 命名编译配置，使用指定的编译器和参数编译某种语言
+type ProfileConfig struct {
+	Name           string   `toml:"name"`
+	DisplayName    string   `toml:"display_name"`
+	Language       string   `toml:"language"`
+	Compiler       string   `toml:"compiler"`
+	Flags          []string `toml:"flags"`
+	LinkFlags      []string `toml:"link_flags"`
+	CompileTimeout int      `toml:"compile_timeout"`
+	RunTimeout     int      `toml:"run_timeout"`
+	MemoryLimit    int      `toml:"memory_limit"`
+}
+
+// AnalyzerConfig 静态分析工具配置
+type AnalyzerConfig struct {
+	Name      string   `toml:"name"`
+	Tool      string   `toml:"tool"`
+	Path      string   `toml:"path"`
+	Checks    string   `toml:"checks"`
+	Args      []string `toml:"args"`
+	Languages []string `toml:"languages"`
+	Timeout   int      `toml:"timeout"`
+}
+
 var AppConfig Config
 
 func LoadConfig(configPath string) error {
-	if _, err := toml.DecodeFile(configPath, &AppConfig); err != nil {
+	md, err := toml.DecodeFile(configPath, &AppConfig)
+	if err != nil {
 		return err
 	}
 	log.Printf("配置加载成功: %s", configPath)
+
+	// [compiler] 中的编译器和编译参数只用于生成默认的 C++ 工具链
+	if len(AppConfig.Languages) > 0 {
+		for _, key := range []string{"compiler", "compile_flags"} {
+			if md.IsDefined("compiler", key) {
+				log.Printf("警告: 已配置 [[languages]]，[compiler] %s 不会生效", key)
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleAnalyze 用配置的静态分析工具检查工作区代码，结果广播给房间
+func handleAnalyze(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	code, language, files := workspaceSource(hub, data)
+	lang, err := services.GetLanguage(language)
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	lang = lang.WithFiles(files)
+
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	run := startRun(client, hub, services.RunKindAnalyze, data)
+	defer hub.FinishRun(run)
+
+	var result *models.AnalysisResult
+	message := ""
+	if !waitQueue(client, run, ticket) {
+		message = "排队时已取消"
+	} else if result, err = services.Analyze(ticket.Context(run.Context()), lang, code); err != nil {
+		message = err.Error()
+	} else {
+		message = fmt.Sprintf("发现 %d 个问题", len(result.Findings))
+		for _, a := range result.Analyzers {
+			if a.Status != services.AnalyzerOK {
+				message += fmt.Sprintf("\n%s: %s", a.Name, a.Message)
+			}
+		}
+	}
+
+	logMsg := fmt.Sprintf("\n[%s] %s 进行了静态分析 (%s)\n%s\n",
+		time.Now().Format("15:04:05"),
+		client.DisplayName,
+		lang.Label(),
+		message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
+
+	resultMsg := models.WebSocketMessage{
+		Type:        "analyze_result",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":       run.ID,
+			"result":      result,
+			"message":     message,
+			"language":    lang.Name,
+			"runBy":       client.DisplayName,
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"cancelledBy": run.CancelledBy(),
+		},
+	}
+	resultData, _ := json.Marshal(resultMsg)
+	hub.BroadcastMessage(resultData)
+}
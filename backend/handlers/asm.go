@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleCompileAsm 将源文件编译为汇编，按源代码行分块后广播给房间
+// 与普通编译一样进入评测队列，使用所选编译配置的编译器和超时
+func handleCompileAsm(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	code, language, files := workspaceSource(hub, data)
+	path, _ := data["path"].(string)
+	optimization, _ := data["optimization"].(string)
+	profile, _ := data["profile"].(string)
+	lang, err := services.ResolveLanguage(language, profile, stringList(data["flags"]))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	lang = lang.WithFiles(files)
+	if lang, err = services.AsmLanguage(lang, optimization); err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	file, err := services.AsmSourceFile(lang, path)
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	run := startRun(client, hub, services.RunKindAsm, data)
+	defer hub.FinishRun(run)
+
+	var result *models.AsmResult
+	if !waitQueue(client, run, ticket) {
+		result = &models.AsmResult{File: file, Optimization: optimization, Blocks: []models.AsmBlock{}, Message: "排队时已取消"}
+	} else {
+		result = services.CompileAsm(ticket.Context(run.Context()), lang, code, file, optimization)
+	}
+
+	message := result.Message
+	if result.Success {
+		lines := 0
+		for _, block := range result.Blocks {
+			lines += len(block.Asm)
+		}
+		message = fmt.Sprintf("生成汇编 %d 行", lines)
+		if result.Truncated {
+			message += "（已截断）"
+		}
+	}
+	logMsg := fmt.Sprintf("\n[%s] %s 查看了 %s 的汇编 (%s)\n%s\n",
+		time.Now().Format("15:04:05"),
+		client.DisplayName,
+		file,
+		lang.Label(),
+		message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
+
+	resultMsg := models.WebSocketMessage{
+		Type:        "asm_result",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":       run.ID,
+			"result":      result,
+			"language":    lang.Name,
+			"profile":     lang.ProfileName(),
+			"runBy":       client.DisplayName,
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"cancelledBy": run.CancelledBy(),
+		},
+	}
+	resultData, _ := json.Marshal(resultMsg)
+	hub.BroadcastMessage(resultData)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleBenchmark 基准测试：以同一输入多次运行当前代码（可选与另一版本对比），统计结果随编译记录保存
+func handleBenchmark(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	code, language, files := workspaceSource(hub, data)
+	input, _ := data["input"].(string)
+	compareCode, _ := data["compareCode"].(string)
+	if input == "" {
+		input = hub.GetSharedState().InputData
+	}
+	profile, _ := data["profile"].(string)
+	lang, err := services.ResolveLanguage(language, profile, stringList(data["flags"]))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	lang = lang.WithFiles(files)
+	runs, _ := data["runs"].(float64)
+	warmup, ok := data["warmup"].(float64)
+	if !ok {
+		warmup = -1
+	}
+
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	run := startRun(client, hub, services.RunKindBenchmark, data)
+	defer hub.FinishRun(run)
+
+	var result *models.CompileResult
+	if !waitQueue(client, run, ticket) {
+		result = queueCancelledResult()
+	} else {
+		result = services.RunBenchmark(lang, code, input, services.BenchmarkOptions{
+			Runs:        int(runs),
+			Warmup:      int(warmup),
+			CompareCode: compareCode,
+			OnProgress: func(done, total int) {
+				broadcastBenchmarkProgress(client, hub, run, done, total)
+			},
+			Ctx: ticket.Context(run.Context()),
+		})
+	}
+
+	hub.AddCompileRecord(client.Username, lang, result)
+
+	logMsg := fmt.Sprintf("\n[%s] %s 进行了基准测试 (%s)\n%s\n",
+		time.Now().Format("15:04:05"),
+		client.DisplayName,
+		lang.Label(),
+		result.Message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
+
+	resultMsg := models.WebSocketMessage{
+		Type:        "benchmark_result",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":       run.ID,
+			"success":     result.Success,
+			"verdict":     result.Verdict,
+			"message":     result.Message,
+			"signal":      result.Signal,
+			"exitCode":    result.ExitCode,
+			"stats":       result.Stats,
+			"diagnostics": result.Diagnostics,
+			"benchmark":   result.Benchmark,
+			"language":    lang.Name,
+			"profile":     lang.ProfileName(),
+			"runBy":       client.DisplayName,
+			"records":     hub.GetCompileRecords(),
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"cancelledBy": run.CancelledBy(),
+		},
+	}
+	resultData, _ := json.Marshal(resultMsg)
+	hub.BroadcastMessage(resultData)
+}
+
+// broadcastBenchmarkProgress 广播基准测试进度（已完成的运行次数，含预热）
+func broadcastBenchmarkProgress(client *services.Client, hub *services.CollaborationHub, run *services.RunHandle, done, total int) {
+	progressMsg := models.WebSocketMessage{
+		Type:        "benchmark_progress",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId": run.ID,
+			"done":  done,
+			"total": total,
+		},
+	}
+	progressData, _ := json.Marshal(progressMsg)
+	hub.BroadcastMessage(progressData)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleCheckerChange 更新输出检查器配置，返回是否需要广播
+func handleCheckerChange(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) bool {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	checker := models.CheckerConfig{}
+	checker.Mode, _ = data["mode"].(string)
+	checker.AbsEps, _ = data["absEps"].(float64)
+	checker.RelEps, _ = data["relEps"].(float64)
+	checker.Code, _ = data["code"].(string)
+	checker.Language, _ = data["language"].(string)
+
+	if err := services.ValidateCheckerConfig(checker); err != nil {
+		sendError(client, err.Error())
+		return false
+	}
+	hub.UpdateChecker(checker)
+	return true
+}
+
+// handleInteractorChange 更新交互器配置，返回是否需要广播
+func handleInteractorChange(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) bool {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	interactor := models.InteractorConfig{}
+	interactor.Code, _ = data["code"].(string)
+	interactor.Language, _ = data["language"].(string)
+
+	if err := services.ValidateInteractorConfig(interactor); err != nil {
+		sendError(client, err.Error())
+		return false
+	}
+	hub.UpdateInteractor(interactor)
+	return true
+}
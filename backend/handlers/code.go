@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"net/http"
 	"time"
 
+	"cocode/backend/models"
 	"cocode/backend/services"
 )
 
@@ -28,14 +31,25 @@ func HandleCodeDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	codeState := hub.GetCodeState()
+	codeState := hub.CodeSnapshot()
+	if len(codeState.Files) > 1 {
+		writeWorkspaceZip(w, codeState)
+		return
+	}
+
 	code := codeState.Code
 	if code == "" {
 		code = "// 空代码\n"
 	}
 
+	// 根据工作区语言确定扩展名
+	ext := ".cpp"
+	if lang, err := services.GetLanguage(codeState.Language); err == nil {
+		ext = lang.Extension()
+	}
+
 	// 设置下载响应头
-	filename := fmt.Sprintf("code_%s.cpp", time.Now().Format("20060102_150405"))
+	filename := fmt.Sprintf("code_%s%s", time.Now().Format("20060102_150405"), ext)
 	w.Header().Set("Content-Type", "text/plain")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(code)))
@@ -43,3 +57,33 @@ func HandleCodeDownload(w http.ResponseWriter, r *http.Request) {
 	// 写入代码内容
 	w.Write([]byte(code))
 }
+
+// writeWorkspaceZip 将工作区中的所有文件打包为 zip 下载
+func writeWorkspaceZip(w http.ResponseWriter, codeState models.CodeState) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range codeState.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Path,
+			Method:   zip.Deflate,
+			Modified: f.Updated,
+		})
+		if err == nil {
+			_, err = fw.Write([]byte(f.Content))
+		}
+		if err != nil {
+			http.Error(w, "打包失败", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		http.Error(w, "打包失败", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("code_%s.zip", time.Now().Format("20060102_150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleDebugStart 以 -g -O0 编译代码并在 GDB 中启动，停在 main 的第一行
+// 调试状态（当前行、调用栈、变量）广播给房间内所有成员，会话随运行取消、超时或发起者断开而结束
+func handleDebugStart(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	code, language, files := workspaceSource(hub, data)
+	input, _ := data["input"].(string)
+	if input == "" {
+		input = hub.GetSharedState().InputData
+	}
+	profile, _ := data["profile"].(string)
+	lang, err := services.ResolveLanguage(language, profile, stringList(data["flags"]))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	lang = lang.WithFiles(files)
+	if lang, err = services.DebugLanguage(lang); err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	if err := services.CheckDebugger(); err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	if hub.GetDebugger() != nil {
+		sendError(client, "已有正在进行的调试会话")
+		return
+	}
+	shareControl, _ := data["shareControl"].(bool)
+	breakpoints := intList(data["breakpoints"])
+
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	run := startRun(client, hub, services.RunKindDebug, data)
+	defer hub.FinishRun(run)
+
+	var result *models.CompileResult
+	if !waitQueue(client, run, ticket) {
+		result = queueCancelledResult()
+	} else {
+		session := services.NewDebugSession(ticket.Context(run.Context()), client, shareControl)
+		if !hub.AttachDebugger(session) {
+			sendError(client, "已有正在进行的调试会话")
+			return
+		}
+		defer hub.DetachDebugger(session)
+
+		onStart := func() {
+			// 程序启动后会话大多在等待操作，不再占用评测名额
+			ticket.Release()
+			startMsg := models.WebSocketMessage{
+				Type:        "debug_start",
+				Username:    client.Username,
+				DisplayName: client.DisplayName,
+				Timestamp:   time.Now().Unix(),
+				Data: map[string]interface{}{
+					"runId":        run.ID,
+					"owner":        client.Username,
+					"shareControl": shareControl,
+					"language":     lang.Name,
+					"profile":      lang.ProfileName(),
+				},
+			}
+			startData, _ := json.Marshal(startMsg)
+			hub.BroadcastMessage(startData)
+		}
+		onState := func(state models.DebugState) {
+			broadcastDebugState(client, hub, run.ID, state)
+		}
+		onOutput := func(stream, output string) {
+			broadcastRunOutput(client, hub, stream, output)
+		}
+		result = session.Run(lang, code, input, breakpoints, onStart, onState, onOutput)
+	}
+
+	hub.AddCompileRecord(client.Username, lang, result)
+
+	logMsg := fmt.Sprintf("\n[%s] %s 进行了调试 (%s)\n%s\n",
+		time.Now().Format("15:04:05"),
+		client.DisplayName,
+		lang.Label(),
+		result.Message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
+
+	endMsg := models.WebSocketMessage{
+		Type:        "debug_end",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":       run.ID,
+			"success":     result.Success,
+			"verdict":     result.Verdict,
+			"message":     result.Message,
+			"exitCode":    result.ExitCode,
+			"signal":      result.Signal,
+			"diagnostics": result.Diagnostics,
+			"records":     hub.GetCompileRecords(),
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"cancelledBy": run.CancelledBy(),
+		},
+	}
+	endData, _ := json.Marshal(endMsg)
+	hub.BroadcastMessage(endData)
+}
+
+// handleDebugCommand 执行调试命令，结果广播给房间内所有成员
+// 未开启共享控制时只有发起调试的用户可以操作
+func handleDebugCommand(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	command, _ := data["command"].(string)
+	line, _ := data["line"].(float64)
+	expression, _ := data["expression"].(string)
+
+	session := hub.GetDebugger()
+	if session == nil {
+		sendError(client, "没有正在进行的调试会话")
+		return
+	}
+	if !session.CanControl(client) {
+		sendError(client, "只有发起调试的用户可以操作")
+		return
+	}
+	if command == "stop" {
+		session.Close()
+		return
+	}
+
+	value, err := session.Command(command, int(line), expression)
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	if value == nil {
+		// 控制运行的命令，程序停止后广播新的调试状态
+		return
+	}
+
+	resultMsg := models.WebSocketMessage{
+		Type:        "debug_result",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"command":    command,
+			"line":       int(line),
+			"expression": expression,
+			"value":      value,
+		},
+	}
+	resultData, _ := json.Marshal(resultMsg)
+	hub.BroadcastMessage(resultData)
+}
+
+// broadcastDebugState 广播调试状态（程序停止、继续运行或结束）
+func broadcastDebugState(client *services.Client, hub *services.CollaborationHub, runID string, state models.DebugState) {
+	stateMsg := models.WebSocketMessage{
+		Type:        "debug_state",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId": runID,
+			"state": state,
+		},
+	}
+	stateData, _ := json.Marshal(stateMsg)
+	hub.BroadcastMessage(stateData)
+}
+
+// debugSnapshot 新加入的客户端看到的调试会话，没有调试时为 nil
+func debugSnapshot(hub *services.CollaborationHub) interface{} {
+	session := hub.GetDebugger()
+	if session == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"owner":        session.Owner.Username,
+		"shareControl": session.ShareControl,
+		"state":        session.State(),
+	}
+}
+
+// intList 将消息中的数字数组转换为 []int，忽略非数字元素
+func intList(v interface{}) []int {
+	items, _ := v.([]interface{})
+	list := make([]int, 0, len(items))
+	for _, item := range items {
+		if n, ok := item.(float64); ok {
+			list = append(list, int(n))
+		}
+	}
+	return list
+}
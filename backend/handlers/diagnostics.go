@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// scheduleSyntaxCheck 代码或语言变化后安排后台语法检查，完成后广播诊断
+func scheduleSyntaxCheck(hub *services.CollaborationHub) {
+	syntaxChecker.Schedule(hub.CodeSnapshot(), func(result *services.SyntaxCheckResult) {
+		// 检查期间代码已更新时丢弃结果，等待新版本的检查
+		if hub.CodeSnapshot().Version != result.Version {
+			return
+		}
+		diagMsg := models.WebSocketMessage{
+			Type:        "diagnostics",
+			Username:    "system",
+			DisplayName: "系统",
+			Timestamp:   time.Now().Unix(),
+			Data: map[string]interface{}{
+				"version":     result.Version,
+				"language":    result.Language,
+				"diagnostics": result.Diagnostics,
+			},
+		}
+		diagData, _ := json.Marshal(diagMsg)
+		hub.BroadcastMessage(diagData)
+	})
+}
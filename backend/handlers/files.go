@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleFileCreate 在工作区中新建文件并广播
+func handleFileCreate(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	path, _ := data["path"].(string)
+	content, _ := data["content"].(string)
+
+	file, err := hub.CreateFile(path, content)
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	broadcastFileChange(client, hub, "file_create", map[string]interface{}{
+		"path": file.Path,
+		"file": file,
+	})
+}
+
+// handleFileRename 重命名工作区中的文件并广播
+func handleFileRename(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	path, _ := data["path"].(string)
+	newPath, _ := data["newPath"].(string)
+
+	file, err := hub.RenameFile(path, newPath)
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	broadcastFileChange(client, hub, "file_rename", map[string]interface{}{
+		"path":    path,
+		"newPath": file.Path,
+		"file":    file,
+	})
+}
+
+// handleFileDelete 删除工作区中的文件并广播
+func handleFileDelete(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	path, _ := data["path"].(string)
+
+	if err := hub.DeleteFile(path); err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	broadcastFileChange(client, hub, "file_delete", map[string]interface{}{
+		"path": path,
+	})
+}
+
+// workspaceSource 编译使用的代码、语言和工作区中的其他文件，请求中未指定代码、语言时使用工作区当前的主文件和语言
+// 三者取自同一份快照，不会混用不同版本的文件
+func workspaceSource(hub *services.CollaborationHub, data map[string]interface{}) (code, language string, files []models.CodeFile) {
+	state := hub.CodeSnapshot()
+	code, _ = data["code"].(string)
+	language, _ = data["language"].(string)
+	if code == "" {
+		code = state.Code
+	}
+	if language == "" {
+		language = state.Language
+	}
+	return code, language, services.SourceFiles(state)
+}
+
+// broadcastFileChange 广播工作区文件的变化（附带工作区版本号和文件列表），并重新安排语法检查
+func broadcastFileChange(client *services.Client, hub *services.CollaborationHub, msgType string, data map[string]interface{}) {
+	state := hub.CodeSnapshot()
+	paths := make([]string, len(state.Files))
+	for i, f := range state.Files {
+		paths[i] = f.Path
+	}
+	data["version"] = state.Version
+	data["paths"] = paths
+
+	changeMsg := models.WebSocketMessage{
+		Type:        msgType,
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data:        data,
+	}
+	changeData, _ := json.Marshal(changeMsg)
+	hub.BroadcastMessage(changeData)
+
+	scheduleSyntaxCheck(hub)
+}
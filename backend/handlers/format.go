@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleFormat 用房间的格式化风格格式化文件（整个文件或选中的行），结果作为一次编辑广播给所有成员
+func handleFormat(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	path, _ := data["path"].(string)
+	startLine, _ := data["startLine"].(float64)
+	endLine, _ := data["endLine"].(float64)
+
+	file, err := hub.GetFile(path)
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	// 客户端看到的版本已过时，格式化结果会覆盖其他成员的修改
+	if version, ok := data["version"].(float64); ok && int(version) != file.Version {
+		sendError(client, "文件 "+file.Path+" 已被修改，请重试")
+		return
+	}
+
+	style := hub.GetFormatStyle()
+	formatted, err := services.FormatCode(file.Path, file.Content, style, int(startLine), int(endLine))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+
+	if formatted == file.Content {
+		resultMsg := models.WebSocketMessage{
+			Type:        "format_result",
+			Username:    "system",
+			DisplayName: "系统",
+			Timestamp:   time.Now().Unix(),
+			Data: map[string]interface{}{
+				"path":    file.Path,
+				"changed": false,
+				"style":   style,
+			},
+		}
+		resultData, _ := json.Marshal(resultMsg)
+		client.Hub.SendToClient(client, resultData)
+		return
+	}
+
+	updated, version, err := hub.ReplaceFile(file.Path, file.Version, formatted)
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+
+	// 与客户端的 edit 消息格式相同，附带格式化风格
+	editMsg := models.WebSocketMessage{
+		Type:        "edit",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"path":        updated.Path,
+			"code":        updated.Content,
+			"fileVersion": updated.Version,
+			"version":     version,
+			"format":      style,
+		},
+	}
+	editData, _ := json.Marshal(editMsg)
+	hub.BroadcastMessage(editData)
+	scheduleSyntaxCheck(hub)
+}
+
+// handleFormatStyleChange 更新房间的格式化风格，返回是否需要广播
+func handleFormatStyleChange(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) bool {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	style, _ := data["style"].(string)
+
+	style, err := services.CheckFormatStyle(style)
+	if err != nil {
+		sendError(client, err.Error())
+		return false
+	}
+	hub.UpdateFormatStyle(style)
+	data["style"] = style
+	return true
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleFuzz 模糊测试：以工作区中的入口文件编译 libFuzzer 程序，运行限定的时间后报告崩溃输入
+// 测试用例和共享输入作为初始语料；saveAs 为 testcase 时将崩溃输入保存为测试用例
+func handleFuzz(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	code, language, files := workspaceSource(hub, data)
+	harness, _ := data["harness"].(string)
+	profile, _ := data["profile"].(string)
+	lang, err := services.ResolveLanguage(language, profile, stringList(data["flags"]))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	lang = lang.WithFiles(files)
+	if lang, err = services.FuzzLanguage(lang, harness); err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	duration, _ := data["duration"].(float64)
+	saveAs, _ := data["saveAs"].(string)
+
+	var seeds []string
+	if input := hub.GetSharedState().InputData; input != "" {
+		seeds = append(seeds, input)
+	}
+	for _, tc := range hub.GetTestCases() {
+		seeds = append(seeds, tc.Input)
+	}
+
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	run := startRun(client, hub, services.RunKindFuzz, data)
+	defer hub.FinishRun(run)
+
+	var result *models.FuzzResult
+	if waitQueue(client, run, ticket) {
+		result = services.RunFuzz(lang, code, harness, services.FuzzOptions{
+			Duration: time.Duration(duration * float64(time.Second)),
+			Seeds:    seeds,
+			Ctx:      ticket.Context(run.Context()),
+		})
+	} else {
+		result = &models.FuzzResult{Status: services.FuzzCancelled, Harness: harness, Crashes: []models.FuzzCrash{}, Message: "排队时已取消"}
+	}
+
+	// 保存崩溃输入，二进制输入无法作为测试用例
+	if saveAs == stressSaveTestCase && len(result.Crashes) > 0 {
+		for i := range result.Crashes {
+			crash := &result.Crashes[i]
+			if crash.Input == "" && crash.Size > 0 {
+				continue
+			}
+			tc := hub.SaveTestCase(models.TestCase{
+				Name:  fmt.Sprintf("模糊测试 %s 输入 %d", crash.Kind, i+1),
+				Input: crash.Input,
+			})
+			crash.TestCaseID = tc.ID
+		}
+		broadcastTestCases(client, hub)
+	}
+
+	logMsg := fmt.Sprintf("\n[%s] %s 进行了模糊测试 (%s, %s)\n%s\n",
+		time.Now().Format("15:04:05"),
+		client.DisplayName,
+		harness,
+		lang.Label(),
+		result.Message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
+
+	resultMsg := models.WebSocketMessage{
+		Type:        "fuzz_result",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":       run.ID,
+			"result":      result,
+			"savedAs":     saveAs,
+			"language":    lang.Name,
+			"profile":     lang.ProfileName(),
+			"runBy":       client.DisplayName,
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"cancelledBy": run.CancelledBy(),
+		},
+	}
+	resultData, _ := json.Marshal(resultMsg)
+	hub.BroadcastMessage(resultData)
+}
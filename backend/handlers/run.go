@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// startRun 登记一次编译运行并广播运行编号
+// 请求中的 requestId 会原样带回，便于发起者将运行编号与自己的请求对应
+func startRun(client *services.Client, hub *services.CollaborationHub, kind string, data map[string]interface{}) *services.RunHandle {
+	run := hub.StartRun(client, kind)
+	requestID, _ := data["requestId"].(string)
+
+	startMsg := models.WebSocketMessage{
+		Type:        "run_started",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":     run.ID,
+			"kind":      run.Kind,
+			"requestId": requestID,
+			"startedBy": client.DisplayName,
+		},
+	}
+	startData, _ := json.Marshal(startMsg)
+	hub.BroadcastMessage(startData)
+	return run
+}
+
+// handleCancelRun 取消进行中的编译运行（房间内任何成员都可以取消），并广播取消者
+func handleCancelRun(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	runID, _ := data["runId"].(string)
+
+	run := hub.GetRun(runID)
+	if run == nil {
+		sendError(client, "运行不存在或已结束")
+		return
+	}
+	if !run.Cancel(client.DisplayName) {
+		sendError(client, "运行已被 "+run.CancelledBy()+" 取消")
+		return
+	}
+
+	cancelMsg := models.WebSocketMessage{
+		Type:        "run_cancelled",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":       run.ID,
+			"kind":        run.Kind,
+			"startedBy":   run.Owner.DisplayName,
+			"cancelledBy": client.DisplayName,
+		},
+	}
+	cancelData, _ := json.Marshal(cancelMsg)
+	hub.BroadcastMessage(cancelData)
+}
+
+// enterQueue 加入评测队列，超出限额时向客户端发送错误并返回 nil
+func enterQueue(client *services.Client) *services.JudgeTicket {
+	ticket, err := services.GetJudgeQueue().Enter(client.Username)
+	if err != nil {
+		sendError(client, err.Error())
+		return nil
+	}
+	return ticket
+}
+
+// waitQueue 等待轮到运行，期间向发起者推送排队位置；排队时被取消返回 false
+func waitQueue(client *services.Client, run *services.RunHandle, ticket *services.JudgeTicket) bool {
+	err := ticket.Wait(run.Context(), func(position int) {
+		sendQueueStatus(client, run, "queued", position)
+	})
+	if err != nil {
+		return false
+	}
+	sendQueueStatus(client, run, "running", 0)
+	return true
+}
+
+// sendQueueStatus 向发起者发送排队状态
+func sendQueueStatus(client *services.Client, run *services.RunHandle, state string, position int) {
+	queue := services.GetJudgeQueue()
+	running, waiting := queue.Stats()
+	statusMsg := models.WebSocketMessage{
+		Type:        "queue_status",
+		Username:    "system",
+		DisplayName: "系统",
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":    run.ID,
+			"state":    state,
+			"position": position,
+			"running":  running,
+			"waiting":  waiting,
+			"cpuUsed":  queue.CPUUsed(client.Username).Seconds(),
+		},
+	}
+	statusData, _ := json.Marshal(statusMsg)
+	client.Hub.SendToClient(client, statusData)
+}
+
+// queueCancelledResult 排队期间被取消的运行结果
+func queueCancelledResult() *models.CompileResult {
+	return &models.CompileResult{
+		Verdict: models.VerdictCancelled,
+		Message: "排队时已取消",
+	}
+}
+
+// cancelNote 运行被取消时在日志中注明取消者
+func cancelNote(run *services.RunHandle) string {
+	if by := run.CancelledBy(); by != "" {
+		return "\n(已被 " + by + " 取消)"
+	}
+	return ""
+}
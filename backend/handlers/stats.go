@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"cocode/backend/services"
+)
+
+// HandleCompileCacheStats 获取编译缓存统计（仅管理员）
+func HandleCompileCacheStats(w http.ResponseWriter, r *http.Request) {
+	// 验证会话
+	sessionID := r.Header.Get("X-Session-ID")
+	session, err := services.ValidateSession(sessionID)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// 仅管理员可以查看
+	if session.Username != "admin" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"cache":   services.GetCompileCacheStats(),
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// 对拍找到反例后的保存方式
+const (
+	stressSaveTestCase = "testcase" // 保存为新的测试用例
+	stressSaveInput    = "input"    // 载入共享输入
+)
+
+// handleStressRun 对拍：数据生成器 + 暴力解法 + 当前代码，进度实时推送给房间
+func handleStressRun(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	code, language, files := workspaceSource(hub, data)
+	profile, _ := data["profile"].(string)
+	lang, err := services.ResolveLanguage(language, profile, stringList(data["flags"]))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	lang = lang.WithFiles(files)
+
+	generator := stressProgram(data["generator"])
+	brute := stressProgram(data["brute"])
+	if err := services.ValidateStressProgram("数据生成器", generator); err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	if err := services.ValidateStressProgram("暴力解法", brute); err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	iterations, _ := data["iterations"].(float64)
+	seed, _ := data["seed"].(float64)
+	saveAs, _ := data["saveAs"].(string)
+
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	run := startRun(client, hub, services.RunKindStress, data)
+	defer hub.FinishRun(run)
+
+	var result *models.StressResult
+	if waitQueue(client, run, ticket) {
+		result = services.RunStress(lang, code, generator, brute, hub.GetChecker(), services.StressOptions{
+			Iterations: int(iterations),
+			Seed:       int(seed),
+			OnProgress: func(iteration int, elapsed time.Duration) {
+				broadcastStressProgress(client, hub, run, iteration, elapsed)
+			},
+			Ctx: ticket.Context(run.Context()),
+		})
+	} else {
+		result = &models.StressResult{Status: services.StressCancelled, Message: "排队时已取消"}
+	}
+
+	// 保存反例
+	savedTestCase := 0
+	if result.Status == services.StressFound {
+		switch saveAs {
+		case stressSaveTestCase:
+			tc := hub.SaveTestCase(models.TestCase{
+				Name:     fmt.Sprintf("对拍反例 (种子 %d)", result.Seed),
+				Input:    result.Input,
+				Expected: result.Expected,
+			})
+			savedTestCase = tc.ID
+			broadcastTestCases(client, hub)
+		case stressSaveInput:
+			hub.UpdateInputData(result.Input)
+			broadcastInputChange(client, hub, result.Input)
+		}
+	}
+
+	logMsg := fmt.Sprintf("\n[%s] %s 进行了对拍 (%s)\n%s\n",
+		time.Now().Format("15:04:05"),
+		client.DisplayName,
+		lang.Label(),
+		result.Message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
+
+	resultMsg := models.WebSocketMessage{
+		Type:        "stress_result",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":       run.ID,
+			"result":      result,
+			"savedAs":     saveAs,
+			"testCaseId":  savedTestCase,
+			"language":    lang.Name,
+			"profile":     lang.ProfileName(),
+			"runBy":       client.DisplayName,
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"cancelledBy": run.CancelledBy(),
+		},
+	}
+	resultData, _ := json.Marshal(resultMsg)
+	hub.BroadcastMessage(resultData)
+}
+
+// stressProgram 解析消息中的辅助程序
+func stressProgram(v interface{}) models.StressProgram {
+	p := models.StressProgram{}
+	if m, ok := v.(map[string]interface{}); ok {
+		p.Code, _ = m["code"].(string)
+		p.Language, _ = m["language"].(string)
+	}
+	return p
+}
+
+// broadcastStressProgress 广播对拍进度
+func broadcastStressProgress(client *services.Client, hub *services.CollaborationHub, run *services.RunHandle, iteration int, elapsed time.Duration) {
+	progressMsg := models.WebSocketMessage{
+		Type:        "stress_progress",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"runId":     run.ID,
+			"iteration": iteration,
+			"elapsed":   elapsed.Milliseconds(),
+		},
+	}
+	progressData, _ := json.Marshal(progressMsg)
+	hub.BroadcastMessage(progressData)
+}
+
+// broadcastInputChange 广播共享输入的变化（与客户端的 input_change 消息格式相同）
+func broadcastInputChange(client *services.Client, hub *services.CollaborationHub, input string) {
+	inputMsg := models.WebSocketMessage{
+		Type:        "input_change",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"input": input,
+		},
+	}
+	inputData, _ := json.Marshal(inputMsg)
+	hub.BroadcastMessage(inputData)
+}
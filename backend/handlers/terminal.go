@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// runInTerminal 在伪终端中编译运行，返回 nil 表示未能开始运行（已向客户端发送错误）
+func runInTerminal(ctx context.Context, client *services.Client, hub *services.CollaborationHub, lang *services.Language, code string, shareInput bool) *models.CompileResult {
+	session := services.NewTerminalSession(ctx, client, shareInput)
+	if !hub.AttachTerminal(session) {
+		sendError(client, "已有程序在终端中运行")
+		return nil
+	}
+	defer hub.DetachTerminal(session)
+
+	onStart := func() {
+		broadcastTerminalState(client, hub, "terminal_start", shareInput)
+	}
+	onOutput := func(stream, output string) {
+		broadcastRunOutput(client, hub, stream, output)
+	}
+	result := session.Run(lang, code, onStart, onOutput)
+	broadcastTerminalState(client, hub, "terminal_end", shareInput)
+	return result
+}
+
+// broadcastTerminalState 广播终端会话的开始或结束
+func broadcastTerminalState(client *services.Client, hub *services.CollaborationHub, msgType string, shareInput bool) {
+	stateMsg := models.WebSocketMessage{
+		Type:        msgType,
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"owner":      client.Username,
+			"shareInput": shareInput,
+		},
+	}
+	stateData, _ := json.Marshal(stateMsg)
+	hub.BroadcastMessage(stateData)
+}
+
+// handleRunStdin 将键盘输入转发给终端中运行的程序
+func handleRunStdin(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	input, _ := data["input"].(string)
+	if input == "" {
+		return
+	}
+
+	session := hub.GetTerminal()
+	if session == nil {
+		sendError(client, "没有正在终端中运行的程序")
+		return
+	}
+	if !session.CanWrite(client) {
+		sendError(client, "只有发起运行的用户可以输入")
+		return
+	}
+	if err := session.Write(input); err != nil {
+		sendError(client, err.Error())
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cocode/backend/models"
+	"cocode/backend/services"
+)
+
+// handleTestCaseSave 新建或修改测试用例
+func handleTestCaseSave(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	tc := models.TestCase{}
+	if id, ok := data["id"].(float64); ok {
+		tc.ID = int(id)
+	}
+	tc.Name, _ = data["name"].(string)
+	tc.Input, _ = data["input"].(string)
+	tc.Expected, _ = data["expected"].(string)
+
+	hub.SaveTestCase(tc)
+	broadcastTestCases(client, hub)
+}
+
+// handleTestCaseDelete 删除测试用例
+func handleTestCaseDelete(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	id, _ := data["id"].(float64)
+	if !hub.DeleteTestCase(int(id)) {
+		sendError(client, "测试用例不存在")
+		return
+	}
+	broadcastTestCases(client, hub)
+}
+
+// broadcastTestCases 广播最新的测试用例列表
+func broadcastTestCases(client *services.Client, hub *services.CollaborationHub) {
+	listMsg := models.WebSocketMessage{
+		Type:        "testcases",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"testCases": hub.GetTestCases(),
+		},
+	}
+	listData, _ := json.Marshal(listMsg)
+	hub.BroadcastMessage(listData)
+}
+
+// handleRunTests 编译一次并运行全部（或指定的）测试用例
+func handleRunTests(client *services.Client, msg models.WebSocketMessage, hub *services.CollaborationHub) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	code, language, files := workspaceSource(hub, data)
+	profile, _ := data["profile"].(string)
+	lang, err := services.ResolveLanguage(language, profile, stringList(data["flags"]))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	lang = lang.WithFiles(files)
+	if coverage, _ := data["coverage"].(bool); coverage {
+		if lang, err = services.CoverageLanguage(lang); err != nil {
+			sendError(client, err.Error())
+			return
+		}
+	}
+
+	cases := selectTestCases(hub.GetTestCases(), data["ids"])
+	if len(cases) == 0 {
+		sendError(client, "没有可运行的测试用例")
+		return
+	}
+
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	run := startRun(client, hub, services.RunKindTests, data)
+	defer hub.FinishRun(run)
+
+	var result *models.CompileResult
+	var results []models.TestCaseResult
+	if waitQueue(client, run, ticket) {
+		result, results = services.RunTests(lang, code, cases, hub.GetChecker(), services.RunOptions{
+			Ctx: ticket.Context(run.Context()),
+		})
+	} else {
+		result = queueCancelledResult()
+	}
+
+	hub.AddCompileRecord(client.Username, lang, result)
+
+	logMsg := fmt.Sprintf("\n[%s] %s 运行了测试用例 (%s)\n%s\n",
+		time.Now().Format("15:04:05"),
+		client.DisplayName,
+		lang.Label(),
+		result.Message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
+
+	passed := 0
+	for _, r := range results {
+		if r.Verdict == models.VerdictAC || r.Verdict == models.VerdictOK {
+			passed++
+		}
+	}
+
+	broadcastMsg := models.WebSocketMessage{
+		Type:        "test_results",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"success":     result.Success,
+			"verdict":     result.Verdict,
+			"message":     result.Message,
+			"results":     results,
+			"passed":      passed,
+			"total":       len(cases),
+			"language":    lang.Name,
+			"profile":     lang.ProfileName(),
+			"runBy":       client.DisplayName,
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"runId":       run.ID,
+			"cancelledBy": run.CancelledBy(),
+			"records":     hub.GetCompileRecords(),
+			"diagnostics": result.Diagnostics,
+			"cached":      result.Cached,
+			"coverage":    result.Coverage,
+		},
+	}
+	broadcastData, _ := json.Marshal(broadcastMsg)
+	hub.BroadcastMessage(broadcastData)
+}
+
+// selectTestCases 按编号筛选测试用例，未指定编号时返回全部
+func selectTestCases(cases []models.TestCase, ids interface{}) []models.TestCase {
+	idList, ok := ids.([]interface{})
+	if !ok || len(idList) == 0 {
+		return cases
+	}
+
+	wanted := make(map[int]bool, len(idList))
+	for _, id := range idList {
+		if v, ok := id.(float64); ok {
+			wanted[int(v)] = true
+		}
+	}
+
+	selected := make([]models.TestCase, 0, len(wanted))
+	for _, tc := range cases {
+		if wanted[tc.ID] {
+			selected = append(selected, tc)
+		}
+	}
+	return selected
+}
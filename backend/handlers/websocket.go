@@ -23,9 +23,13 @@ var upgrader = websocket.Upgrader{
 
 var hub *services.CollaborationHub
 
+// syntaxChecker 后台语法检查器
+var syntaxChecker *services.SyntaxChecker
+
 // InitWebSocketHub 初始化WebSocket中心
 func InitWebSocketHub() {
 	hub = services.NewCollaborationHub()
+	syntaxChecker = services.NewSyntaxChecker()
 	go hub.Run()
 }
 
@@ -64,7 +68,7 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	hub.RegisterClient(client)
 
 	// 发送当前代码状态和共享状态
-	codeState := hub.GetCodeState()
+	codeState := hub.CodeSnapshot()
 	sharedState := hub.GetSharedState()
 	initMessage := models.WebSocketMessage{
 		Type:        "init",
@@ -73,10 +77,23 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		Timestamp:   time.Now().Unix(),
 		Data: map[string]interface{}{
 			"code":        codeState.Code,
+			"language":    codeState.Language,
+			"mainFile":    codeState.MainFile,
+			"files":       codeState.Files,
+			"version":     codeState.Version,
+			"languages":   services.ListLanguages(),
+			"profiles":    services.ListProfiles(),
 			"inputData":   sharedState.InputData,
 			"outputData":  sharedState.OutputData,
 			"compileLog":  sharedState.CompileLog,
 			"answer":      sharedState.Answer,
+			"testCases":   hub.GetTestCases(),
+			"checker":     hub.GetChecker(),
+			"interactor":  hub.GetInteractor(),
+			"formatStyle": hub.GetFormatStyle(),
+			"records":     hub.GetCompileRecords(),
+			"syntaxCheck": syntaxChecker.Last(),
+			"debug":       debugSnapshot(hub),
 		},
 	}
 	initData, _ := json.Marshal(initMessage)
@@ -105,6 +122,8 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 func readPump(client *services.Client, hub *services.CollaborationHub) {
 	defer func() {
 		hub.UnregisterClient(client)
+		hub.CloseClientTerminal(client)
+		hub.CloseClientDebugger(client)
 		client.Conn.Close()
 
 		// 广播用户离开
@@ -151,12 +170,45 @@ func readPump(client *services.Client, hub *services.CollaborationHub) {
 		// 处理不同类型的消息
 		switch wsMsg.Type {
 		case "edit":
-			// 更新代码状态
-			if data, ok := wsMsg.Data.(map[string]interface{}); ok {
-				if code, ok := data["code"].(string); ok {
-					hub.UpdateCodeState(code)
-				}
+			// 更新文件内容，未指定文件时为主文件
+			data, ok := wsMsg.Data.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			code, ok := data["code"].(string)
+			if !ok {
+				continue
+			}
+			path, _ := data["path"].(string)
+			file, version, err := hub.UpdateFile(path, code)
+			if err != nil {
+				sendError(client, err.Error())
+				continue
+			}
+			data["path"] = file.Path
+			data["fileVersion"] = file.Version
+			data["version"] = version
+			scheduleSyntaxCheck(hub)
+		case "language_change":
+			// 切换编程语言
+			data, ok := wsMsg.Data.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := data["language"].(string)
+			lang, err := services.GetLanguage(name)
+			if err != nil {
+				sendError(client, err.Error())
+				continue
 			}
+			version, err := hub.UpdateLanguage(lang)
+			if err != nil {
+				sendError(client, err.Error())
+				continue
+			}
+			data["mainFile"] = lang.SourceFile
+			data["version"] = version
+			scheduleSyntaxCheck(hub)
 		case "input_change":
 			// 输入数据变化
 			if data, ok := wsMsg.Data.(map[string]interface{}); ok {
@@ -175,6 +227,83 @@ func readPump(client *services.Client, hub *services.CollaborationHub) {
 			// 编译请求（异步处理）
 			go handleCompileRequest(client, wsMsg, hub)
 			continue // 不广播编译请求
+		case "checker_change":
+			// 输出检查器配置变化
+			if !handleCheckerChange(client, wsMsg, hub) {
+				continue
+			}
+		case "format_style_change":
+			// 房间的格式化风格变化
+			if !handleFormatStyleChange(client, wsMsg, hub) {
+				continue
+			}
+		case "format":
+			// 格式化代码（异步处理）
+			go handleFormat(client, wsMsg, hub)
+			continue
+		case "interactor_change":
+			// 交互器配置变化
+			if !handleInteractorChange(client, wsMsg, hub) {
+				continue
+			}
+		case "testcase_save":
+			handleTestCaseSave(client, wsMsg, hub)
+			continue
+		case "testcase_delete":
+			handleTestCaseDelete(client, wsMsg, hub)
+			continue
+		case "cancel_run":
+			// 取消进行中的编译运行
+			handleCancelRun(client, wsMsg, hub)
+			continue
+		case "run_stdin":
+			// 终端模式下的键盘输入
+			handleRunStdin(client, wsMsg, hub)
+			continue
+		case "run_tests":
+			// 批量运行测试用例（异步处理）
+			go handleRunTests(client, wsMsg, hub)
+			continue
+		case "file_create":
+			// 新建文件
+			handleFileCreate(client, wsMsg, hub)
+			continue
+		case "file_rename":
+			// 重命名文件
+			handleFileRename(client, wsMsg, hub)
+			continue
+		case "file_delete":
+			// 删除文件
+			handleFileDelete(client, wsMsg, hub)
+			continue
+		case "stress_run":
+			// 对拍（异步处理）
+			go handleStressRun(client, wsMsg, hub)
+			continue
+		case "analyze":
+			// 静态分析（异步处理）
+			go handleAnalyze(client, wsMsg, hub)
+			continue
+		case "compile_asm":
+			// 查看汇编（异步处理）
+			go handleCompileAsm(client, wsMsg, hub)
+			continue
+		case "benchmark":
+			// 基准测试（异步处理）
+			go handleBenchmark(client, wsMsg, hub)
+			continue
+		case "fuzz":
+			// 模糊测试（异步处理）
+			go handleFuzz(client, wsMsg, hub)
+			continue
+		case "debug_start":
+			// 开始调试（异步处理，持续到调试结束）
+			go handleDebugStart(client, wsMsg, hub)
+			continue
+		case "debug_command":
+			// 调试命令（断点、单步、查看变量等）
+			go handleDebugCommand(client, wsMsg, hub)
+			continue
 		case "kick_user":
 			// 踢人请求（仅管理员）
 			if client.Username == "admin" {
@@ -243,8 +372,19 @@ func handleCompileRequest(client *services.Client, msg models.WebSocketMessage,
 		return
 	}
 
-	code, _ := data["code"].(string)
+	code, language, files := workspaceSource(hub, data)
 	input, _ := data["input"].(string)
+	answer, _ := data["answer"].(string)
+	mode, _ := data["mode"].(string)
+
+	profile, _ := data["profile"].(string)
+	lang, err := services.ResolveLanguage(language, profile, stringList(data["flags"]))
+	if err != nil {
+		sendError(client, err.Error())
+		return
+	}
+	// 工作区中的其他文件（头文件、其他编译单元）一同编译
+	lang = lang.WithFiles(files)
 
 	// 获取共享输入数据（如果请求中没有指定）
 	if input == "" {
@@ -252,20 +392,90 @@ func handleCompileRequest(client *services.Client, msg models.WebSocketMessage,
 		input = sharedState.InputData
 	}
 
+	// 标准答案（如果请求中没有指定）
+	if answer == "" {
+		answer = hub.GetSharedState().Answer
+	}
+
+	// 诊断模式：以 AddressSanitizer/UBSan 编译运行
+	if mode == "diagnose" {
+		if lang, err = services.SanitizerLanguage(lang); err != nil {
+			sendError(client, err.Error())
+			return
+		}
+	}
+
+	// 覆盖率模式：以 --coverage 编译，运行后返回各行的执行次数
+	if mode == "coverage" {
+		if lang, err = services.CoverageLanguage(lang); err != nil {
+			sendError(client, err.Error())
+			return
+		}
+	}
+
+	if mode == "terminal" && hub.GetTerminal() != nil {
+		sendError(client, "已有程序在终端中运行")
+		return
+	}
+
+	// 加入评测队列，超出限额时直接拒绝
+	ticket := enterQueue(client)
+	if ticket == nil {
+		return
+	}
+	defer ticket.Done()
+
+	// 登记运行，客户端可凭运行编号取消
+	run := startRun(client, hub, services.RunKindCompile, data)
+	defer hub.FinishRun(run)
+	ctx := ticket.Context(run.Context())
+
 	// 执行编译
-	result := services.CompileAndRun(code, input)
+	var result *models.CompileResult
+	if !waitQueue(client, run, ticket) {
+		result = queueCancelledResult()
+	} else if mode == "terminal" {
+		// 终端模式：在伪终端中运行，输入来自 run_stdin 消息
+		shareInput, _ := data["shareInput"].(bool)
+		result = runInTerminal(ctx, client, hub, lang, code, shareInput)
+		if result == nil {
+			return
+		}
+	} else if mode == "diagnose" {
+		result = services.CompileAndDiagnose(lang, code, input, services.RunOptions{
+			OnOutput: func(stream, output string) {
+				broadcastRunOutput(client, hub, stream, output)
+			},
+			Ctx: ctx,
+		})
+	} else if mode == "interactive" {
+		// 交互模式：与交互器对接运行，由交互器给出判定
+		result = services.CompileAndInteract(lang, code, input, hub.GetInteractor(), services.RunOptions{
+			Ctx: ctx,
+		})
+	} else {
+		result = services.CompileAndRun(lang, code, input, services.RunOptions{
+			OnOutput: func(stream, output string) {
+				broadcastRunOutput(client, hub, stream, output)
+			},
+			Ctx: ctx,
+		})
+
+		// 与标准答案比对
+		services.CheckResult(ctx, result, hub.GetChecker(), input, answer)
+	}
 
 	// 添加编译记录
-	hub.AddCompileRecord(client.Username, result.Success)
+	hub.AddCompileRecord(client.Username, lang, result)
 
 	// 更新共享输出和日志
 	hub.UpdateOutputData(result.Output)
-	logMsg := fmt.Sprintf("\n[%s] %s 执行了编译\n%s\n",
+	logMsg := fmt.Sprintf("\n[%s] %s 执行了编译 (%s)\n%s\n",
 		time.Now().Format("15:04:05"),
 		client.DisplayName,
-		result.Message)
-	currentLog := hub.GetSharedState().CompileLog
-	hub.UpdateCompileLog(currentLog + logMsg)
+		lang.Label(),
+		result.Message+cancelNote(run))
+	hub.AppendCompileLog(logMsg)
 
 	// 广播编译结果给所有用户
 	broadcastMsg := models.WebSocketMessage{
@@ -274,14 +484,74 @@ func handleCompileRequest(client *services.Client, msg models.WebSocketMessage,
 		DisplayName: client.DisplayName,
 		Timestamp:   time.Now().Unix(),
 		Data: map[string]interface{}{
-			"success":    result.Success,
-			"message":    result.Message,
-			"output":     result.Output,
-			"compiledBy": client.DisplayName,
-			"compileLog": hub.GetSharedState().CompileLog,
+			"success":     result.Success,
+			"message":     result.Message,
+			"output":      result.Output,
+			"verdict":     result.Verdict,
+			"signal":      result.Signal,
+			"exitCode":    result.ExitCode,
+			"stats":       result.Stats,
+			"diagnostics": result.Diagnostics,
+			"cached":      result.Cached,
+			"sanitizer":   result.Sanitizer,
+			"coverage":    result.Coverage,
+			"records":     hub.GetCompileRecords(),
+			"check":       result.CheckMessage,
+			"language":    lang.Name,
+			"profile":     lang.ProfileName(),
+			"compiledBy":  client.DisplayName,
+			"compileLog":  hub.GetSharedState().CompileLog,
+			"runId":       run.ID,
+			"cancelledBy": run.CancelledBy(),
 		},
 	}
 
 	broadcastData, _ := json.Marshal(broadcastMsg)
 	hub.BroadcastMessage(broadcastData)
 }
+
+// broadcastRunOutput 广播运行中的程序输出
+func broadcastRunOutput(client *services.Client, hub *services.CollaborationHub, stream, output string) {
+	outputMsg := models.WebSocketMessage{
+		Type:        "run_output",
+		Username:    client.Username,
+		DisplayName: client.DisplayName,
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"stream": stream,
+			"output": output,
+		},
+	}
+	outputData, _ := json.Marshal(outputMsg)
+	hub.BroadcastMessage(outputData)
+}
+
+// sendError 向单个客户端发送错误消息
+func sendError(client *services.Client, message string) {
+	errMsg := models.WebSocketMessage{
+		Type:        "error",
+		Username:    "system",
+		DisplayName: "系统",
+		Timestamp:   time.Now().Unix(),
+		Data: map[string]interface{}{
+			"message": message,
+		},
+	}
+	errData, _ := json.Marshal(errMsg)
+	client.Hub.SendToClient(client, errData)
+}
+
+// stringList 将消息中的字符串数组转换为 []string，忽略非字符串元素
+func stringList(v interface{}) []string {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
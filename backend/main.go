@@ -17,6 +17,12 @@ import (
 var embeddedFiles embed.FS
 
 func main() {
+	// 沙箱辅助进程（运行用户程序时由服务端重新执行自身）
+	if services.IsSandboxHelper() {
+		services.RunSandboxHelper()
+		return
+	}
+
 	// 加载配置
 	configPath := "config.toml"
 	if len(os.Args) > 1 {
@@ -62,6 +68,9 @@ func main() {
 	mux.HandleFunc("/api/users/update", handlers.HandleUpdateUser)
 	mux.HandleFunc("/api/users/delete", handlers.HandleDeleteUser)
 
+	// 统计API（仅管理员）
+	mux.HandleFunc("/api/stats/compile-cache", handlers.HandleCompileCacheStats)
+
 	// 静态文件服务
 	var staticFS http.FileSystem
 
@@ -20,11 +20,11 @@ type Session struct {
 
 // WebSocketMessage WebSocket消息结构
 type WebSocketMessage struct {
-	Type        string      `json:"type"`               // message类型: edit, cursor, chat, compile, run
-	Username    string      `json:"username"`           // 发送者用户名
-	DisplayName string      `json:"displayName"`        // 发送者显示名称
-	Timestamp   int64       `json:"timestamp"`          // 时间戳
-	Data        interface{} `json:"data"`               // 消息数据
+	Type        string      `json:"type"`        // message类型: edit, cursor, chat, compile, run
+	Username    string      `json:"username"`    // 发送者用户名
+	DisplayName string      `json:"displayName"` // 发送者显示名称
+	Timestamp   int64       `json:"timestamp"`   // 时间戳
+	Data        interface{} `json:"data"`        // 消息数据
 }
 
 // EditOperation 编辑操作
@@ -58,32 +58,341 @@ type CompileRequest struct {
 	Input string `json:"input"` // 输入数据
 }
 
+// 运行判定
+const (
+	VerdictOK   = "OK"   // 正常结束
+	VerdictCE   = "CE"   // 编译错误
+	VerdictTLE  = "TLE"  // 超出时间限制
+	VerdictMLE  = "MLE"  // 超出内存限制
+	VerdictRE   = "RE"   // 运行时错误
+	VerdictOLE  = "OLE"  // 输出超出限制
+	VerdictAC   = "AC"   // 答案正确
+	VerdictWA   = "WA"   // 答案错误
+	VerdictFail = "FAIL" // 检查器（特判程序）出错
+
+	VerdictCancelled = "CANCELLED" // 被用户取消
+)
+
+// 输出比对方式
+const (
+	CheckerExact      = "exact"            // 完全一致
+	CheckerTolerant   = "tolerant"         // 忽略行尾空白和末尾空行（默认）
+	CheckerIgnoreCase = "case_insensitive" // 在 tolerant 基础上忽略大小写
+	CheckerFloat      = "float"            // 按数据比对，浮点数允许误差
+	CheckerSpecial    = "special"          // 自定义特判程序（testlib 风格）
+)
+
+// CheckerConfig 输出检查器配置
+type CheckerConfig struct {
+	Mode     string  `json:"mode"`               // 比对方式
+	AbsEps   float64 `json:"absEps,omitempty"`   // 绝对误差（float 模式）
+	RelEps   float64 `json:"relEps,omitempty"`   // 相对误差（float 模式）
+	Code     string  `json:"code,omitempty"`     // 特判程序源代码（special 模式）
+	Language string  `json:"language,omitempty"` // 特判程序语言（special 模式）
+}
+
+// InteractorConfig 交互题的交互器配置
+type InteractorConfig struct {
+	Code     string `json:"code"`     // 交互器源代码
+	Language string `json:"language"` // 交互器语言
+}
+
+// StressProgram 对拍中的辅助程序（数据生成器、暴力解法）
+type StressProgram struct {
+	Code     string `json:"code"`     // 源代码
+	Language string `json:"language"` // 语言
+}
+
+// StressResult 对拍结果
+type StressResult struct {
+	Status     string `json:"status"`     // found（找到反例）、passed（达到上限未发现差异）、error、cancelled
+	Iterations int    `json:"iterations"` // 已运行的轮数
+	Elapsed    int64  `json:"elapsed"`    // 用时（毫秒）
+	Seed       int    `json:"seed"`       // 反例的随机种子
+	Verdict    string `json:"verdict"`    // 解法在反例上的判定: WA, RE, TLE 等
+	Input      string `json:"input"`      // 反例输入
+	Expected   string `json:"expected"`   // 暴力解法的输出
+	Output     string `json:"output"`     // 解法的输出
+	Diff       string `json:"diff,omitempty"`
+	Message    string `json:"message"` // 日志
+}
+
+// FuzzResult 模糊测试结果
+type FuzzResult struct {
+	Status     string      `json:"status"`     // found（发现崩溃）、passed（达到时间上限未发现崩溃）、error、cancelled
+	Harness    string      `json:"harness"`    // 定义 LLVMFuzzerTestOneInput 的入口文件
+	Executions int64       `json:"executions"` // 执行的输入数
+	Elapsed    int64       `json:"elapsed"`    // 用时（毫秒），不含编译
+	Crashes    []FuzzCrash `json:"crashes"`    // 发现的崩溃，按发现顺序排列
+	Message    string      `json:"message"`    // 日志
+}
+
+// FuzzCrash 模糊测试发现的导致崩溃的输入
+type FuzzCrash struct {
+	Kind        string           `json:"kind"`                 // crash、timeout、oom、leak
+	Input       string           `json:"input"`                // 输入内容（不是有效的 UTF-8 文本时为空）
+	InputBase64 string           `json:"inputBase64"`          // 输入的原始字节
+	Size        int              `json:"size"`                 // 输入长度（字节）
+	Minimized   bool             `json:"minimized"`            // 是否已最小化
+	Report      *SanitizerReport `json:"report,omitempty"`     // Sanitizer 报告
+	TestCaseID  int              `json:"testCaseId,omitempty"` // 保存为测试用例时的编号
+}
+
 // CompileResult 编译结果
 type CompileResult struct {
-	Success bool   `json:"success"` // 编译是否成功
-	Message string `json:"message"` // 编译信息/错误
-	Output  string `json:"output"`  // 运行输出
+	Success  bool   `json:"success"`          // 是否成功: 判定为 OK，与标准答案比对后为 AC
+	Message  string `json:"message"`          // 编译信息/错误
+	Output   string `json:"output"`           // 运行输出
+	Verdict  string `json:"verdict"`          // 判定: OK, CE, TLE, MLE, RE, OLE；与标准答案比对后为 AC, WA
+	Signal   string `json:"signal,omitempty"` // 终止信号（运行时错误时）
+	ExitCode int    `json:"exitCode"`         // 程序退出码
+
+	CheckMessage string    `json:"checkMessage,omitempty"` // 答案比对说明
+	Stats        *RunStats `json:"stats,omitempty"`        // 运行统计（程序未运行时为空）
+
+	Diagnostics []Diagnostic      `json:"diagnostics"`         // 结构化的编译诊断
+	Cached      bool              `json:"cached"`              // 是否使用了编译缓存
+	Sanitizer   []SanitizerReport `json:"sanitizer,omitempty"` // Sanitizer 报告（诊断运行）
+	Coverage    *CoverageReport   `json:"coverage,omitempty"`  // 行覆盖率（覆盖率运行）
+	Benchmark   *BenchmarkResult  `json:"benchmark,omitempty"` // 基准测试结果
+}
+
+// CoverageReport 覆盖率运行中 gcov 统计的各文件行执行次数
+type CoverageReport struct {
+	Files []FileCoverage `json:"files"`
+}
+
+// FileCoverage 工作区中单个文件的行覆盖率
+type FileCoverage struct {
+	File    string         `json:"file"`
+	Lines   []LineCoverage `json:"lines"`   // 可执行的行，按行号排序；未列出的行不含代码
+	Covered int            `json:"covered"` // 执行过的行数
+	Total   int            `json:"total"`   // 可执行的行数
+}
+
+// LineCoverage 一行的执行次数，行号从 1 开始
+type LineCoverage struct {
+	Line     int   `json:"line"`
+	Count    int64 `json:"count"`
+	Branches int   `json:"branches,omitempty"` // 该行的分支数
+	Taken    int   `json:"taken,omitempty"`    // 执行过的分支数
+}
+
+// Diagnostic 编译诊断（错误、警告等），行列号从 1 开始
+type Diagnostic struct {
+	File      string  `json:"file"`
+	Line      int     `json:"line"`
+	Column    int     `json:"column"`              // 0 表示未知
+	EndLine   int     `json:"endLine,omitempty"`   // 范围结束位置（可选）
+	EndColumn int     `json:"endColumn,omitempty"` // 范围结束位置（可选，不含）
+	Severity  string  `json:"severity"`            // error, warning, note
+	Message   string  `json:"message"`
+	Fixits    []Fixit `json:"fixits"` // 编译器建议的修改
+}
+
+// Fixit 编译器建议的修改：将 [Line:Column, EndLine:EndColumn) 替换为 Replacement
+type Fixit struct {
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	EndLine     int    `json:"endLine"`
+	EndColumn   int    `json:"endColumn"`
+	Replacement string `json:"replacement"`
+}
+
+// SanitizerReport AddressSanitizer/UBSan 等运行时检查的报告，行列号从 1 开始
+type SanitizerReport struct {
+	Tool    string       `json:"tool"`    // AddressSanitizer、UndefinedBehaviorSanitizer、LeakSanitizer
+	Kind    string       `json:"kind"`    // 错误类型，如 heap-buffer-overflow、SEGV、signed-integer-overflow
+	Message string       `json:"message"` // 报告的说明
+	File    string       `json:"file"`    // 出错位置（用户代码中的第一个栈帧），未知时为空
+	Line    int          `json:"line"`
+	Column  int          `json:"column"`
+	Frames  []StackFrame `json:"frames"` // 出错时的调用栈
+	Raw     string       `json:"raw"`    // 报告原文
+}
+
+// StackFrame 调用栈中的一帧
+type StackFrame struct {
+	Index    int    `json:"index"`
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	User     bool   `json:"user"` // 是否位于用户代码中
+}
+
+// DebugState 调试会话的状态，行号从 1 开始
+type DebugState struct {
+	State       string            `json:"state"`            // starting、running、stopped、exited
+	Reason      string            `json:"reason,omitempty"` // 停止原因: breakpoint-hit、end-stepping-range、signal-received 等
+	Signal      string            `json:"signal,omitempty"` // 收到的信号
+	ExitCode    int               `json:"exitCode"`         // 程序退出码（exited 时有效）
+	File        string            `json:"file,omitempty"`   // 当前位置（文件、行号、函数）
+	Line        int               `json:"line,omitempty"`
+	Function    string            `json:"function,omitempty"`
+	Frames      []StackFrame      `json:"frames"`      // 调用栈
+	Locals      []DebugVariable   `json:"locals"`      // 当前栈帧的局部变量和参数
+	Breakpoints []DebugBreakpoint `json:"breakpoints"` // 断点
+}
+
+// DebugVariable 调试中的变量
+type DebugVariable struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Arg   bool   `json:"arg"` // 是否为函数参数
+}
+
+// DebugBreakpoint 断点
+type DebugBreakpoint struct {
+	Number int `json:"number"` // 调试器中的断点编号
+	Line   int `json:"line"`
+	Hits   int `json:"hits"` // 命中次数
+}
+
+// RunStats 程序运行的资源使用统计
+type RunStats struct {
+	UserTime int64  `json:"userTime"`         // 用户态 CPU 时间（毫秒）
+	SysTime  int64  `json:"sysTime"`          // 内核态 CPU 时间（毫秒）
+	WallTime int64  `json:"wallTime"`         // 墙钟时间（毫秒）
+	MaxRSS   int64  `json:"maxRss"`           // 峰值常驻内存（KB）
+	ExitCode int    `json:"exitCode"`         // 退出码
+	Signal   string `json:"signal,omitempty"` // 终止信号
+}
+
+// BenchmarkResult 基准测试结果
+type BenchmarkResult struct {
+	Runs     int                `json:"runs"`               // 每个版本计时运行的次数
+	Warmup   int                `json:"warmup"`             // 每个版本预热运行的次数（不计入统计）
+	Versions []BenchmarkVersion `json:"versions"`           // 当前代码，以及用于对比的代码（可选）
+	CPURatio float64            `json:"cpuRatio,omitempty"` // 对比代码与当前代码 CPU 时间中位数之比
+	Elapsed  int64              `json:"elapsed"`            // 耗时（毫秒），不含编译
+}
+
+// BenchmarkVersion 一个代码版本的基准测试统计
+type BenchmarkVersion struct {
+	Name     string         `json:"name"`     // current, compare
+	CPUTime  BenchmarkStats `json:"cpuTime"`  // CPU 时间（毫秒，用户态 + 内核态）
+	WallTime BenchmarkStats `json:"wallTime"` // 墙钟时间（毫秒）
+	MaxRSS   BenchmarkStats `json:"maxRss"`   // 峰值常驻内存（KB）
+	Samples  []float64      `json:"samples"`  // 各次运行的 CPU 时间（毫秒）
+}
+
+// BenchmarkStats 多次运行的统计值
+type BenchmarkStats struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	Stddev float64 `json:"stddev"` // 样本标准差
+}
+
+// AnalysisResult 静态分析结果
+type AnalysisResult struct {
+	Findings  []AnalysisFinding `json:"findings"`  // 发现的问题，按文件和行号排序
+	Analyzers []AnalyzerRun     `json:"analyzers"` // 各分析工具的运行情况
+	Elapsed   int64             `json:"elapsed"`   // 耗时（毫秒）
+}
+
+// AnalysisFinding 静态分析发现的问题，行号从 1 开始
+type AnalysisFinding struct {
+	Analyzer string `json:"analyzer"` // 分析工具名称
+	Check    string `json:"check"`    // 检查项，如 bugprone-use-after-move、nullPointer
+	Severity string `json:"severity"` // error, warning, note
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"` // 0 表示未知
+	Message  string `json:"message"`
+}
+
+// AnalyzerRun 单个分析工具的运行情况
+type AnalyzerRun struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`            // ok, missing, failed, timeout, cancelled
+	Message  string `json:"message,omitempty"` // 未正常完成时的说明
+	Findings int    `json:"findings"`          // 发现的问题数
+}
+
+// AsmResult 汇编查看结果
+type AsmResult struct {
+	Success      bool         `json:"success"`
+	File         string       `json:"file"`         // 生成汇编的源文件
+	Optimization string       `json:"optimization"` // 优化级别，如 -O2，为空表示使用编译命令中的设置
+	Blocks       []AsmBlock   `json:"blocks"`       // 按出现顺序排列的汇编块
+	Truncated    bool         `json:"truncated"`    // 汇编过长被截断
+	Message      string       `json:"message"`      // 编译输出，失败时为错误信息
+	Diagnostics  []Diagnostic `json:"diagnostics"`  // 编译器诊断
+	Elapsed      int64        `json:"elapsed"`      // 耗时（毫秒）
+}
+
+// AsmBlock 对应同一源代码行的一段连续汇编，行号从 1 开始
+type AsmBlock struct {
+	File string   `json:"file,omitempty"` // 工作区中的源文件，为空表示没有对应的源代码（数据、系统头文件等）
+	Line int      `json:"line,omitempty"`
+	Asm  []string `json:"asm"`
 }
 
 // CodeState 代码状态（用于协同编辑）
 type CodeState struct {
-	Code    string    `json:"code"`    // 当前代码
-	Version int       `json:"version"` // 版本号
+	Code     string     `json:"code"`     // 主文件的代码
+	Language string     `json:"language"` // 编程语言
+	Version  int        `json:"version"`  // 工作区版本号，任一文件或语言变化时递增
+	Updated  time.Time  `json:"updated"`  // 更新时间
+	MainFile string     `json:"mainFile"` // 主文件路径（与语言的源文件名相同）
+	Files    []CodeFile `json:"files"`    // 工作区中的文件，第一个为主文件
+}
+
+// CodeFile 工作区中的文件
+type CodeFile struct {
+	Path    string    `json:"path"`    // 相对路径，如 util.h、graph/dijkstra.cpp
+	Content string    `json:"content"` // 文件内容
+	Version int       `json:"version"` // 文件版本号
 	Updated time.Time `json:"updated"` // 更新时间
 }
 
 // SharedState 共享状态（输入、输出、日志）
 type SharedState struct {
-	InputData   string    `json:"inputData"`   // 输入数据
-	OutputData  string    `json:"outputData"`  // 输出数据
-	CompileLog  string    `json:"compileLog"`  // 编译日志
-	Answer      string    `json:"answer"`      // 标准答案
-	Updated     time.Time `json:"updated"`     // 更新时间
+	InputData   string           `json:"inputData"`   // 输入数据
+	OutputData  string           `json:"outputData"`  // 输出数据
+	CompileLog  string           `json:"compileLog"`  // 编译日志
+	Answer      string           `json:"answer"`      // 标准答案
+	TestCases   []TestCase       `json:"testCases"`   // 测试用例
+	Checker     CheckerConfig    `json:"checker"`     // 输出检查器
+	Interactor  InteractorConfig `json:"interactor"`  // 交互器
+	FormatStyle string           `json:"formatStyle"` // 代码格式化风格，为空时使用配置中的默认风格
+	Updated     time.Time        `json:"updated"`     // 更新时间
+}
+
+// TestCase 测试用例
+type TestCase struct {
+	ID       int    `json:"id"`       // 用例编号
+	Name     string `json:"name"`     // 用例名称
+	Input    string `json:"input"`    // 输入数据
+	Expected string `json:"expected"` // 期望输出（为空时不比对）
+}
+
+// TestCaseResult 单个测试用例的运行结果
+type TestCaseResult struct {
+	ID      int    `json:"id"`             // 用例编号
+	Name    string `json:"name"`           // 用例名称
+	Verdict string `json:"verdict"`        // 判定: AC, WA, TLE, MLE, RE, OLE
+	Time    int64  `json:"time"`           // CPU 时间（毫秒）
+	Memory  int64  `json:"memory"`         // 峰值内存（KB）
+	Output  string `json:"output"`         // 程序输出
+	Diff    string `json:"diff,omitempty"` // 差异摘要
 }
 
 // CompileRecord 编译记录
 type CompileRecord struct {
-	Username  string    `json:"username"`  // 执行编译的用户
-	Timestamp time.Time `json:"timestamp"` // 编译时间
-	Success   bool      `json:"success"`   // 是否成功
+	Username  string           `json:"username"`            // 执行编译的用户
+	Timestamp time.Time        `json:"timestamp"`           // 编译时间
+	Success   bool             `json:"success"`             // 是否成功
+	Language  string           `json:"language"`            // 编程语言
+	Profile   string           `json:"profile"`             // 编译配置，未使用时为空
+	Flags     []string         `json:"flags,omitempty"`     // 附加的编译参数
+	Verdict   string           `json:"verdict"`             // 判定
+	Cached    bool             `json:"cached"`              // 是否使用了编译缓存
+	Stats     *RunStats        `json:"stats,omitempty"`     // 运行统计
+	Benchmark *BenchmarkResult `json:"benchmark,omitempty"` // 基准测试结果
 }
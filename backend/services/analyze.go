@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 支持的静态分析工具
+const (
+	AnalyzerClangTidy = "clang-tidy"
+	AnalyzerCppcheck  = "cppcheck"
+)
+
+// 分析工具的运行状态
+const (
+	AnalyzerOK        = "ok"
+	AnalyzerMissing   = "missing"   // 服务器未安装
+	AnalyzerFailed    = "failed"    // 运行出错且没有输出结果
+	AnalyzerTimeout   = "timeout"   // 超时
+	AnalyzerCancelled = "cancelled" // 被取消
+)
+
+// cppcheckTemplate cppcheck 的输出格式，与 cppcheckPattern 对应
+const cppcheckTemplate = "{file}:{line}:{column}:{severity}:{id}:{message}"
+
+var (
+	// clangTidyPattern main.cpp:5:10: warning: message [check-name]
+	clangTidyPattern = regexp.MustCompile(`^(.+?):(\d+):(\d+): (warning|error): (.*?)(?: \[([^\]]+)\])?$`)
+	// cppcheckPattern main.cpp:5:10:style:variableScope:message
+	cppcheckPattern = regexp.MustCompile(`^(.+?):(\d+):(\d+):(\w+):([\w-]+):(.*)$`)
+)
+
+// analyzersFor 适用于指定语言的分析工具
+func analyzersFor(lang *Language) []config.AnalyzerConfig {
+	var analyzers []config.AnalyzerConfig
+	for _, a := range config.AppConfig.Analyzers {
+		for _, name := range a.Languages {
+			if name == lang.Name {
+				analyzers = append(analyzers, a)
+				break
+			}
+		}
+	}
+	return analyzers
+}
+
+// analyzerCommand 生成分析工具的命令行，sources 为需要分析的源文件
+func analyzerCommand(a config.AnalyzerConfig, sources []string) ([]string, error) {
+	path := a.Path
+	if path == "" {
+		path = a.Tool
+	}
+	switch a.Tool {
+	case AnalyzerClangTidy:
+		args := []string{path, "--quiet", "--header-filter=.*"}
+		if a.Checks != "" {
+			args = append(args, "--checks="+a.Checks)
+		}
+		args = append(args, sources...)
+		return append(append(args, "--"), a.Args...), nil
+	case AnalyzerCppcheck:
+		args := []string{path, "--quiet", "--inline-suppr", "--template=" + cppcheckTemplate}
+		if a.Checks != "" {
+			args = append(args, "--enable="+a.Checks)
+		}
+		args = append(args, a.Args...)
+		return append(args, sources...), nil
+	}
+	return nil, fmt.Errorf("不支持的分析工具: %s", a.Tool)
+}
+
+// Analyze 用配置的静态分析工具检查代码（包括工作区中的其他文件），汇总带位置的问题
+func Analyze(ctx context.Context, lang *Language, code string) (*models.AnalysisResult, error) {
+	analyzers := analyzersFor(lang)
+	if len(analyzers) == 0 {
+		return nil, fmt.Errorf("没有适用于 %s 的静态分析工具", lang.DisplayName)
+	}
+
+	tempDir := config.AppConfig.Compiler.TempDir
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %v", err)
+	}
+	dir, err := os.MkdirTemp(tempDir, "analyze_")
+	if err != nil {
+		return nil, fmt.Errorf("创建运行目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := writeSources(dir, lang, code); err != nil {
+		return nil, fmt.Errorf("写入源文件失败: %v", err)
+	}
+	// 分析工具输出的是解析符号链接后的绝对路径
+	absDir, err := filepath.Abs(dir)
+	if err == nil {
+		absDir, err = filepath.EvalSymlinks(absDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sources := append([]string{lang.SourceFile}, lang.translationUnits()...)
+
+	start := time.Now()
+	result := &models.AnalysisResult{
+		Findings:  []models.AnalysisFinding{},
+		Analyzers: []models.AnalyzerRun{},
+	}
+	for _, a := range analyzers {
+		findings, run := runAnalyzer(ctx, a, lang, absDir, sources)
+		result.Findings = append(result.Findings, findings...)
+		result.Analyzers = append(result.Analyzers, run)
+	}
+
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		fi, fj := result.Findings[i], result.Findings[j]
+		if fi.File != fj.File {
+			return fi.File < fj.File
+		}
+		return fi.Line < fj.Line
+	})
+	if len(result.Findings) > maxDiagnostics {
+		result.Findings = result.Findings[:maxDiagnostics]
+	}
+	result.Elapsed = time.Since(start).Milliseconds()
+	return result, nil
+}
+
+// runAnalyzer 运行单个分析工具并解析输出
+func runAnalyzer(parent context.Context, a config.AnalyzerConfig, lang *Language, dir string, sources []string) ([]models.AnalysisFinding, models.AnalyzerRun) {
+	run := models.AnalyzerRun{Name: a.Name, Status: AnalyzerOK}
+	args, err := analyzerCommand(a, sources)
+	if err != nil {
+		run.Status, run.Message = AnalyzerFailed, err.Error()
+		return nil, run
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		run.Status, run.Message = AnalyzerMissing, fmt.Sprintf("服务器未安装 %s", args[0])
+		return nil, run
+	}
+
+	timeout := lang.CompileTimeout
+	if a.Timeout > 0 {
+		timeout = time.Duration(a.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.WaitDelay = time.Second
+	killGroupOnCancel(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if state := cmd.ProcessState; state != nil {
+		chargeCPU(parent, state.UserTime()+state.SystemTime())
+	}
+	switch {
+	case parent.Err() != nil:
+		run.Status, run.Message = AnalyzerCancelled, "分析已取消"
+		return nil, run
+	case ctx.Err() == context.DeadlineExceeded:
+		run.Status, run.Message = AnalyzerTimeout, fmt.Sprintf("分析超时（%v）", timeout)
+		return nil, run
+	}
+
+	var findings []models.AnalysisFinding
+	switch a.Tool {
+	case AnalyzerClangTidy:
+		findings = parseClangTidy(stdout.String(), dir)
+	case AnalyzerCppcheck:
+		findings = parseCppcheck(stderr.String(), dir)
+	}
+	for i := range findings {
+		findings[i].Analyzer = a.Name
+	}
+	run.Findings = len(findings)
+
+	// clang-tidy 发现编译错误时返回非零，只要有结果就视为正常完成
+	if err != nil && len(findings) == 0 {
+		run.Status = AnalyzerFailed
+		run.Message = strings.TrimSpace(stderr.String() + "\n" + stdout.String())
+		if run.Message == "" {
+			run.Message = err.Error()
+		}
+		run.Message = truncate(run.Message, 2000)
+	}
+	return findings, run
+}
+
+// parseClangTidy 解析 clang-tidy 的输出，跳过附属的 note 和工作区以外（系统头文件）的问题
+func parseClangTidy(output, dir string) []models.AnalysisFinding {
+	var findings []models.AnalysisFinding
+	for _, line := range strings.Split(output, "\n") {
+		m := clangTidyPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		file, ok := workspacePath(m[1], dir)
+		if !ok {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		check := m[6]
+		if check == "" {
+			check = "clang-diagnostic-" + m[4]
+		}
+		findings = append(findings, models.AnalysisFinding{
+			Check:    check,
+			Severity: normalizeSeverity(m[4]),
+			File:     file,
+			Line:     lineNo,
+			Column:   column,
+			Message:  m[5],
+		})
+	}
+	return findings
+}
+
+// parseCppcheck 解析 cppcheck 按 cppcheckTemplate 输出的结果，跳过没有位置的提示
+func parseCppcheck(output, dir string) []models.AnalysisFinding {
+	var findings []models.AnalysisFinding
+	for _, line := range strings.Split(output, "\n") {
+		m := cppcheckPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		file, ok := workspacePath(m[1], dir)
+		lineNo, _ := strconv.Atoi(m[2])
+		if !ok || lineNo == 0 {
+			continue
+		}
+		column, _ := strconv.Atoi(m[3])
+		findings = append(findings, models.AnalysisFinding{
+			Check:    m[5],
+			Severity: cppcheckSeverity(m[4]),
+			File:     file,
+			Line:     lineNo,
+			Column:   column,
+			Message:  m[6],
+		})
+	}
+	return findings
+}
+
+// cppcheckSeverity 将 cppcheck 的级别统一为 error、warning、note
+func cppcheckSeverity(severity string) string {
+	switch severity {
+	case "error":
+		return SeverityError
+	case "warning", "style", "performance", "portability":
+		return SeverityWarning
+	}
+	return SeverityNote
+}
+
+// workspacePath 将分析工具输出中的路径转换为工作区中的相对路径，不在运行目录中时返回 false
+func workspacePath(path, dir string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// asmOutputFile 运行目录中生成的汇编文件（以 . 开头，不会与工作区文件冲突）
+const asmOutputFile = ".output.s"
+
+// maxAsmLines 返回的汇编最多行数
+const maxAsmLines = 5000
+
+// demangleTimeout 还原 C++ 符号名的超时
+const demangleTimeout = 5 * time.Second
+
+// asmFlags 生成汇编的编译参数，-g 生成 .loc 行号信息
+var asmFlags = []string{"-S", "-g"}
+
+// asmOptimizations 查看汇编时可选的优化级别
+var asmOptimizations = []string{"-O0", "-O1", "-O2", "-O3", "-Os", "-Og"}
+
+// asmDataDirectives 保留的数据定义伪指令（字符串常量、跳转表等）
+var asmDataDirectives = map[string]bool{
+	".string": true, ".ascii": true, ".asciz": true, ".byte": true, ".short": true,
+	".value": true, ".long": true, ".quad": true, ".zero": true, ".float": true, ".double": true,
+}
+
+var (
+	// asmFilePattern .file 1 "main.cpp" 或 .file 0 "/dir" "main.cpp"
+	asmFilePattern = regexp.MustCompile(`^\.file\s+(\d+)\s+"([^"]*)"(?:\s+"([^"]*)")?`)
+	// asmLocPattern .loc 1 5 3 ...
+	asmLocPattern = regexp.MustCompile(`^\.loc\s+(\d+)\s+(\d+)`)
+	// asmLabelRef 指令中引用的局部标签
+	asmLabelRef = regexp.MustCompile(`\.L\w+`)
+)
+
+// AsmLanguage 生成查看汇编使用的工具链：以 -S -g 和指定的优化级别（可为空）编译
+// 仅支持 GCC 和 Clang 编译的语言
+func AsmLanguage(lang *Language, optimization string) (*Language, error) {
+	if !lang.NeedsCompile() || !isGCCOrClang(lang.CompileCommand[0]) {
+		return nil, fmt.Errorf("%s 不支持查看汇编", lang.DisplayName)
+	}
+	flags := asmFlags
+	if optimization != "" {
+		valid := false
+		for _, o := range asmOptimizations {
+			valid = valid || o == optimization
+		}
+		if !valid {
+			return nil, fmt.Errorf("不支持的优化级别: %s（可选 %s）", optimization, strings.Join(asmOptimizations, "、"))
+		}
+		flags = append(flags[:len(flags):len(flags)], optimization)
+	}
+	l, err := lang.withFlags(flags)
+	if err != nil {
+		return nil, fmt.Errorf("%s 不支持查看汇编", lang.DisplayName)
+	}
+	return l, nil
+}
+
+// AsmSourceFile 校验要查看汇编的源文件：主文件或工作区中的其他编译单元，为空时为主文件
+func AsmSourceFile(lang *Language, path string) (string, error) {
+	if path == "" || path == lang.SourceFile {
+		return lang.SourceFile, nil
+	}
+	for _, unit := range lang.translationUnits() {
+		if unit == path {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s 不是可编译的源文件", path)
+}
+
+// CompileAsm 将源文件编译为汇编，过滤伪指令后按源代码行分块
+// lang 应为 AsmLanguage 返回的工具链，file 为 AsmSourceFile 返回的路径
+func CompileAsm(parent context.Context, lang *Language, code, file, optimization string) *models.AsmResult {
+	result := &models.AsmResult{
+		File:         file,
+		Optimization: optimization,
+		Blocks:       []models.AsmBlock{},
+	}
+	start := time.Now()
+	defer func() { result.Elapsed = time.Since(start).Milliseconds() }()
+
+	tempDir := config.AppConfig.Compiler.TempDir
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		result.Message = fmt.Sprintf("创建临时目录失败: %v", err)
+		return result
+	}
+	dir, err := os.MkdirTemp(tempDir, "asm_")
+	if err != nil {
+		result.Message = fmt.Sprintf("创建运行目录失败: %v", err)
+		return result
+	}
+	defer os.RemoveAll(dir)
+	if err := writeSources(dir, lang, code); err != nil {
+		result.Message = fmt.Sprintf("写入源文件失败: %v", err)
+		return result
+	}
+
+	// 只编译一个源文件，不链接：去掉编译命令中 {src} 之后的输出和链接参数
+	var args []string
+	for _, arg := range lang.CompileCommand {
+		if arg == placeholderSrc {
+			break
+		}
+		args = append(args, arg)
+	}
+	args = append(lang.expandCommand(args), file, "-o", asmOutputFile)
+
+	ctx, cancel := context.WithTimeout(parent, lang.CompileTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.WaitDelay = time.Second
+	killGroupOnCancel(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if state := cmd.ProcessState; state != nil {
+		chargeCPU(parent, state.UserTime()+state.SystemTime())
+	}
+	if err != nil {
+		if parent.Err() != nil {
+			result.Message = "编译已取消"
+			return result
+		}
+		result.Message = fmt.Sprintf("编译失败:\n%s%s", stdout.String(), stderr.String())
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Message += "\n编译超时!"
+			return result
+		}
+		result.Diagnostics = parseTextDiagnostics(stderr.String())
+		return result
+	}
+
+	asm, err := os.ReadFile(filepath.Join(dir, asmOutputFile))
+	if err != nil {
+		result.Message = fmt.Sprintf("读取汇编失败: %v", err)
+		return result
+	}
+	// 编译器记录的是解析符号链接后的绝对路径
+	absDir, err := filepath.Abs(dir)
+	if err == nil {
+		absDir, err = filepath.EvalSymlinks(absDir)
+	}
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+	result.Blocks, result.Truncated = parseAsm(demangle(parent, string(asm)), absDir)
+	result.Success = true
+	result.Message = "编译成功!\n" + stdout.String() + stderr.String()
+	result.Diagnostics = parseTextDiagnostics(stderr.String())
+	return result
+}
+
+// demangle 用 c++filt 还原汇编中的 C++ 符号名，未安装或失败时原样返回
+func demangle(parent context.Context, asm string) string {
+	tool, err := exec.LookPath("c++filt")
+	if err != nil {
+		return asm
+	}
+	ctx, cancel := context.WithTimeout(parent, demangleTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, tool)
+	cmd.Stdin = strings.NewReader(asm)
+	output, err := cmd.Output()
+	if err != nil {
+		return asm
+	}
+	return string(output)
+}
+
+// asmSkippedSection 调试信息等不需要显示的段
+func asmSkippedSection(name string) bool {
+	return strings.HasPrefix(name, ".debug") || strings.HasPrefix(name, ".note") ||
+		strings.HasPrefix(name, ".comment") || strings.HasPrefix(name, ".eh_frame")
+}
+
+// asmLabel 是否为标签定义（从行首开始，以冒号结尾；还原后的 C++ 符号名中可能有空格）
+func asmLabel(raw string) bool {
+	raw = strings.TrimRight(raw, " \t\r")
+	return strings.HasSuffix(raw, ":") && !strings.HasPrefix(raw, " ") && !strings.HasPrefix(raw, "\t")
+}
+
+// asmSection 切换段的伪指令对应的段名，不是切换段时返回 false
+func asmSection(line string) (string, bool) {
+	fields := strings.Fields(strings.ReplaceAll(line, ",", " "))
+	switch fields[0] {
+	case ".text", ".data", ".bss":
+		return fields[0], true
+	case ".section":
+		if len(fields) > 1 {
+			return fields[1], true
+		}
+	}
+	return "", false
+}
+
+// parseAsm 解析 GCC/Clang 生成的汇编：去掉伪指令、注释和未被引用的局部标签，
+// 按 .loc 记录的源代码位置将连续的指令分块；返回的汇编超过 maxAsmLines 行时截断
+func parseAsm(asm, dir string) ([]models.AsmBlock, bool) {
+	lines := strings.Split(asm, "\n")
+
+	// 第一遍：收集指令和数据中引用的标签
+	referenced := make(map[string]bool)
+	skipped := false
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || asmLabel(raw) {
+			continue
+		}
+		if name, ok := asmSection(line); ok {
+			skipped = asmSkippedSection(name)
+			continue
+		}
+		if skipped {
+			continue
+		}
+		if strings.HasPrefix(line, ".") && !asmDataDirectives[strings.Fields(line)[0]] {
+			continue
+		}
+		for _, label := range asmLabelRef.FindAllString(line, -1) {
+			referenced[label] = true
+		}
+	}
+
+	// 第二遍：按源代码位置分块
+	blocks := []models.AsmBlock{}
+	files := make(map[string]string)
+	file, lineNo := "", 0
+	var pending []string // 等待归入下一条指令所在块的标签
+	keepData := false    // 当前数据是否属于保留的标签
+	total := 0
+	skipped = false
+
+	emit := func(text string) bool {
+		if total+len(pending)+1 > maxAsmLines {
+			return false
+		}
+		n := len(blocks)
+		if n == 0 || blocks[n-1].File != file || blocks[n-1].Line != lineNo || len(pending) > 0 {
+			blocks = append(blocks, models.AsmBlock{File: file, Line: lineNo})
+			n++
+		}
+		blocks[n-1].Asm = append(blocks[n-1].Asm, pending...)
+		blocks[n-1].Asm = append(blocks[n-1].Asm, text)
+		total += len(pending) + 1
+		pending = nil
+		return true
+	}
+
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, ok := asmSection(line); ok {
+			skipped = asmSkippedSection(name)
+			file, lineNo, pending, keepData = "", 0, nil, false
+			continue
+		}
+		if skipped {
+			continue
+		}
+
+		if asmLabel(raw) {
+			label := strings.TrimSuffix(line, ":")
+			keepData = !strings.HasPrefix(label, ".") || referenced[label]
+			if keepData {
+				pending = append(pending, line)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, ".") {
+			if m := asmFilePattern.FindStringSubmatch(line); m != nil {
+				path := m[2]
+				if m[3] != "" {
+					path = filepath.Join(m[2], m[3])
+				}
+				files[m[1]] = path
+				continue
+			}
+			if m := asmLocPattern.FindStringSubmatch(line); m != nil {
+				file, lineNo = "", 0
+				n, _ := strconv.Atoi(m[2])
+				if path, ok := workspacePath(files[m[1]], dir); ok && files[m[1]] != "" && n > 0 {
+					file, lineNo = path, n
+				}
+				continue
+			}
+			directive := strings.Fields(line)[0]
+			if !asmDataDirectives[directive] || !keepData {
+				continue
+			}
+			if !emit("  " + strings.ReplaceAll(line, "\t", " ")) {
+				return blocks, true
+			}
+			continue
+		}
+
+		if !emit("  " + strings.ReplaceAll(line, "\t", " ")) {
+			return blocks, true
+		}
+	}
+	return blocks, false
+}
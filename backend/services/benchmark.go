@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 基准测试中的代码版本
+const (
+	BenchmarkCurrent = "current" // 当前代码
+	BenchmarkCompare = "compare" // 用于对比的代码
+)
+
+// 未配置 [benchmark] 时的默认值
+const (
+	defaultBenchmarkRuns      = 10
+	defaultBenchmarkWarmup    = 1
+	defaultBenchmarkMaxRuns   = 50
+	defaultBenchmarkMaxWarmup = 5
+	defaultBenchmarkTimeLimit = 60 * time.Second
+)
+
+// benchmarkNames 代码版本的显示名称
+var benchmarkNames = map[string]string{
+	BenchmarkCurrent: "当前代码",
+	BenchmarkCompare: "对比代码",
+}
+
+// BenchmarkOptions 基准测试选项
+type BenchmarkOptions struct {
+	Runs        int                   // 每个版本计时运行的次数，0 时使用默认值，超过配置上限时使用上限
+	Warmup      int                   // 每个版本预热运行的次数，负数时使用默认值
+	CompareCode string                // 用于对比的代码（替换主文件，工作区中的其他文件不变），为空时不对比
+	OnProgress  func(done, total int) // 进度回调，按推送间隔节流，可为空
+	Ctx         context.Context       // 取消基准测试的上下文，为空时不可取消
+}
+
+// benchmarkRuns 本次基准测试的计时次数和预热次数
+func benchmarkRuns(runs, warmup int) (int, int) {
+	maxRuns := config.AppConfig.Benchmark.MaxRuns
+	if maxRuns <= 0 {
+		maxRuns = defaultBenchmarkMaxRuns
+	}
+	maxWarmup := config.AppConfig.Benchmark.MaxWarmup
+	if maxWarmup <= 0 {
+		maxWarmup = defaultBenchmarkMaxWarmup
+	}
+	if runs <= 0 {
+		runs = defaultBenchmarkRuns
+	}
+	if warmup < 0 {
+		warmup = defaultBenchmarkWarmup
+	}
+	if runs > maxRuns {
+		runs = maxRuns
+	}
+	if warmup > maxWarmup {
+		warmup = maxWarmup
+	}
+	return runs, warmup
+}
+
+// benchmarkTimeLimit 基准测试的时间上限
+func benchmarkTimeLimit() time.Duration {
+	if s := config.AppConfig.Benchmark.TimeLimit; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultBenchmarkTimeLimit
+}
+
+// benchmarkSample 一次计时运行的测量值
+type benchmarkSample struct {
+	cpu, wall, rss float64
+}
+
+// RunBenchmark 基准测试：编译代码（和对比代码）后以同一输入预热，再交替计时运行，统计 CPU 时间、墙钟时间和峰值内存
+// 任一次运行失败时停止并给出判定；达到时间上限时以已完成的运行统计
+func RunBenchmark(lang *Language, code, input string, opts BenchmarkOptions) *models.CompileResult {
+	ctx := opts.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := &models.CompileResult{}
+
+	names := []string{BenchmarkCurrent}
+	codes := []string{code}
+	if opts.CompareCode != "" {
+		names = append(names, BenchmarkCompare)
+		codes = append(codes, opts.CompareCode)
+	}
+	builds := make([]*build, 0, len(names))
+	defer func() {
+		for _, b := range builds {
+			b.cleanup()
+		}
+	}()
+	for i, name := range names {
+		b, compiled := compileSource(ctx, lang, codes[i])
+		if b == nil {
+			result.Verdict = compiled.Verdict
+			result.Diagnostics = compiled.Diagnostics
+			result.Message = compiled.Message
+			if name == BenchmarkCompare {
+				result.Message = benchmarkNames[name] + compiled.Message
+			}
+			return result
+		}
+		if name == BenchmarkCurrent {
+			result.Diagnostics = compiled.Diagnostics
+			result.Cached = compiled.Cached
+		}
+		builds = append(builds, b)
+	}
+
+	runs, warmup := benchmarkRuns(opts.Runs, opts.Warmup)
+	limit := benchmarkTimeLimit()
+	interval := streamInterval()
+	start := time.Now()
+	lastProgress := start
+	samples := make([][]benchmarkSample, len(builds))
+	outputs := make([]string, len(builds))
+	total := (runs + warmup) * len(builds)
+	done := 0
+	stopped := ""
+
+	// 先预热，之后各版本交替运行，减少机器负载变化对比较的影响
+loop:
+	for i := 0; i < warmup+runs; i++ {
+		for j, b := range builds {
+			if ctx.Err() != nil {
+				result.Verdict = models.VerdictCancelled
+				result.Message = fmt.Sprintf("基准测试已取消: 已运行 %d/%d 次", done, total)
+				return result
+			}
+			if time.Since(start) > limit {
+				stopped = fmt.Sprintf("达到时间上限（%v），", limit)
+				break loop
+			}
+
+			req := b.command(strings.NewReader(input))
+			req.Ctx = ctx
+			outcome := runSandboxed(req)
+			if outcome.Cancelled {
+				continue
+			}
+			if verdict := outcome.Verdict(); verdict != models.VerdictOK {
+				result.Verdict = verdict
+				result.Signal = outcome.Signal
+				result.ExitCode = outcome.ExitCode
+				result.Stats = outcome.stats()
+				result.Message = fmt.Sprintf("%s第 %d 次运行失败\n%s%s", benchmarkNames[names[j]], i+1,
+					describeOutcome(outcome), describeStats(result.Stats))
+				return result
+			}
+			done++
+			outputs[j] = outcome.Stdout
+			if i >= warmup {
+				samples[j] = append(samples[j], benchmarkSample{
+					cpu:  float64(outcome.CPUTime.Microseconds()) / 1000,
+					wall: float64(outcome.WallTime.Microseconds()) / 1000,
+					rss:  float64(outcome.MaxRSS),
+				})
+			}
+
+			if opts.OnProgress != nil && time.Since(lastProgress) >= interval {
+				lastProgress = time.Now()
+				opts.OnProgress(done, total)
+			}
+		}
+	}
+
+	// 达到时间上限时各版本完成的次数可能相差一次，按最少的统计
+	completed := runs
+	for _, s := range samples {
+		if len(s) < completed {
+			completed = len(s)
+		}
+	}
+	bench := &models.BenchmarkResult{
+		Runs:     completed,
+		Warmup:   warmup,
+		Versions: make([]models.BenchmarkVersion, 0, len(builds)),
+		Elapsed:  time.Since(start).Milliseconds(),
+	}
+	if bench.Runs == 0 {
+		result.Verdict = models.VerdictTLE
+		result.Message = stopped + "没有完成计时运行"
+		return result
+	}
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s预热 %d 次，计时运行 %d 次", stopped, warmup, bench.Runs)
+	for j, name := range names {
+		version := benchmarkVersion(name, samples[j][:bench.Runs])
+		bench.Versions = append(bench.Versions, version)
+		fmt.Fprintf(&summary, "\n%s: CPU 时间中位数 %.3f ms（最小 %.3f ms，P95 %.3f ms，标准差 %.3f ms），峰值内存中位数 %.0f KB",
+			benchmarkNames[name], version.CPUTime.Median, version.CPUTime.Min, version.CPUTime.P95,
+			version.CPUTime.Stddev, version.MaxRSS.Median)
+	}
+	if len(bench.Versions) > 1 {
+		if base := bench.Versions[0].CPUTime.Median; base > 0 {
+			bench.CPURatio = bench.Versions[1].CPUTime.Median / base
+			fmt.Fprintf(&summary, "\n对比代码 / 当前代码 CPU 时间: %.2fx", bench.CPURatio)
+		}
+		if outputs[0] != outputs[1] {
+			summary.WriteString("\n注意: 两个版本的输出不同")
+		}
+	}
+
+	result.Success = true
+	result.Verdict = models.VerdictOK
+	result.Output = outputs[0]
+	result.Benchmark = bench
+	result.Message = summary.String()
+	return result
+}
+
+// benchmarkVersion 统计一个版本的计时运行
+func benchmarkVersion(name string, samples []benchmarkSample) models.BenchmarkVersion {
+	cpu := make([]float64, len(samples))
+	wall := make([]float64, len(samples))
+	rss := make([]float64, len(samples))
+	for i, s := range samples {
+		cpu[i], wall[i], rss[i] = s.cpu, s.wall, s.rss
+	}
+	return models.BenchmarkVersion{
+		Name:     name,
+		CPUTime:  benchmarkStats(cpu),
+		WallTime: benchmarkStats(wall),
+		MaxRSS:   benchmarkStats(rss),
+		Samples:  cpu,
+	}
+}
+
+// benchmarkStats 计算最小值、中位数、P95（最近秩法）、最大值、均值和样本标准差，values 不能为空
+func benchmarkStats(values []float64) models.BenchmarkStats {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+
+	stats := models.BenchmarkStats{Min: sorted[0], Max: sorted[n-1]}
+	if n%2 == 1 {
+		stats.Median = sorted[n/2]
+	} else {
+		stats.Median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	stats.P95 = sorted[int(math.Ceil(0.95*float64(n)))-1]
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	stats.Mean = sum / float64(n)
+	if n > 1 {
+		variance := 0.0
+		for _, v := range sorted {
+			variance += (v - stats.Mean) * (v - stats.Mean)
+		}
+		stats.Stddev = math.Sqrt(variance / float64(n-1))
+	}
+	return stats
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 编译缓存目录（位于 temp_dir 下）及每个缓存项中的元数据文件
+const (
+	compileCacheDirName  = "cache"
+	compileCacheMetaFile = ".cocode_cache.json"
+)
+
+// CompileCacheStats 编译缓存统计
+type CompileCacheStats struct {
+	Enabled  bool    `json:"enabled"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRate  float64 `json:"hitRate"` // 命中率（0~1）
+	Entries  int     `json:"entries"`
+	Bytes    int64   `json:"bytes"`
+	MaxBytes int64   `json:"maxBytes"`
+}
+
+// compileCache 以源代码、编译器和编译参数的哈希为键缓存编译产物，按最近使用时间淘汰
+type compileCache struct {
+	mu      sync.Mutex
+	dir     string
+	entries map[string]*cacheEntry
+	total   int64
+	hits    int64
+	misses  int64
+}
+
+// cacheEntry 缓存项
+type cacheEntry struct {
+	size int64
+	used time.Time
+}
+
+// cacheMeta 与编译产物一同保存的编译信息，命中时用于还原编译结果
+type cacheMeta struct {
+	Output      string              `json:"output"`
+	Diagnostics []models.Diagnostic `json:"diagnostics"`
+}
+
+var (
+	buildCache     *compileCache
+	buildCacheOnce sync.Once
+)
+
+// getCompileCache 获取编译缓存，首次调用时加载磁盘上已有的缓存项
+func getCompileCache() *compileCache {
+	buildCacheOnce.Do(func() {
+		buildCache = &compileCache{
+			dir:     filepath.Join(config.AppConfig.Compiler.TempDir, compileCacheDirName),
+			entries: make(map[string]*cacheEntry),
+		}
+		buildCache.load()
+	})
+	return buildCache
+}
+
+// maxBytes 缓存容量上限，0 表示不使用缓存
+func (c *compileCache) maxBytes() int64 {
+	return int64(config.AppConfig.Compiler.CacheSize) * 1024 * 1024
+}
+
+// enabled 是否启用编译缓存
+func (c *compileCache) enabled() bool {
+	return c.maxBytes() > 0
+}
+
+// load 扫描缓存目录重建索引，清理未完成的写入
+func (c *compileCache) load() {
+	items, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, item := range items {
+		path := filepath.Join(c.dir, item.Name())
+		if !item.IsDir() || strings.HasPrefix(item.Name(), "tmp_") {
+			os.RemoveAll(path)
+			continue
+		}
+		info, err := item.Info()
+		if err != nil {
+			continue
+		}
+		size := dirSize(path)
+		c.entries[item.Name()] = &cacheEntry{size: size, used: info.ModTime()}
+		c.total += size
+	}
+}
+
+// compileCacheKey 计算缓存键：语言、展开后的编译命令、编译器文件信息、源代码和工作区中的其他文件
+func compileCacheKey(lang *Language, code string) string {
+	args := lang.compileArgs()
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%q\x00", lang.Name, args)
+	// 编译器升级后缓存自动失效
+	if path, err := exec.LookPath(args[0]); err == nil {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	io.WriteString(h, code)
+	for _, f := range lang.Files {
+		fmt.Fprintf(h, "\x00%s\x00%d\x00%s", f.Path, len(f.Content), f.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// restore 命中时将缓存的编译产物复制到运行目录
+func (c *compileCache) restore(key, dstDir string) (*cacheMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	src := filepath.Join(c.dir, key)
+	data, err := os.ReadFile(filepath.Join(src, compileCacheMetaFile))
+	var meta cacheMeta
+	if err == nil {
+		err = json.Unmarshal(data, &meta)
+	}
+	if err == nil {
+		err = copyTree(src, dstDir, compileCacheMetaFile)
+	}
+	if err != nil {
+		log.Printf("读取编译缓存失败: %v", err)
+		c.removeLocked(key)
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	entry.used = time.Now()
+	os.Chtimes(src, entry.used, entry.used)
+	return &meta, true
+}
+
+// store 保存编译产物（不含源文件），超出容量时淘汰最久未使用的缓存项
+func (c *compileCache) store(key, srcDir, sourceFile string, meta cacheMeta) {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	tmp, err := os.MkdirTemp(c.dir, "tmp_")
+	if err != nil {
+		return
+	}
+	data, _ := json.Marshal(meta)
+	err = copyTree(srcDir, tmp, sourceFile)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(tmp, compileCacheMetaFile), data, 0644)
+	}
+	if err != nil {
+		log.Printf("写入编译缓存失败: %v", err)
+		os.RemoveAll(tmp)
+		return
+	}
+	size := dirSize(tmp)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		// 并发编译了相同的代码
+		os.RemoveAll(tmp)
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, key)); err != nil {
+		os.RemoveAll(tmp)
+		return
+	}
+	c.entries[key] = &cacheEntry{size: size, used: time.Now()}
+	c.total += size
+	c.evictLocked()
+}
+
+// evictLocked 按最近使用时间淘汰缓存项直到不超过容量（需持有锁）
+func (c *compileCache) evictLocked() {
+	limit := c.maxBytes()
+	if c.total <= limit {
+		return
+	}
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].used.Before(c.entries[keys[j]].used)
+	})
+	for _, key := range keys {
+		if c.total <= limit {
+			break
+		}
+		c.removeLocked(key)
+	}
+}
+
+// removeLocked 删除缓存项（需持有锁）
+func (c *compileCache) removeLocked(key string) {
+	if entry, ok := c.entries[key]; ok {
+		c.total -= entry.size
+		delete(c.entries, key)
+	}
+	os.RemoveAll(filepath.Join(c.dir, key))
+}
+
+// stats 返回缓存统计
+func (c *compileCache) stats() CompileCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := CompileCacheStats{
+		Enabled:  c.enabled(),
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Entries:  len(c.entries),
+		Bytes:    c.total,
+		MaxBytes: c.maxBytes(),
+	}
+	if total := c.hits + c.misses; total > 0 {
+		s.HitRate = float64(c.hits) / float64(total)
+	}
+	return s
+}
+
+// GetCompileCacheStats 获取编译缓存统计
+func GetCompileCacheStats() CompileCacheStats {
+	return getCompileCache().stats()
+}
+
+// copyTree 复制目录中的文件（保留权限），跳过 skip 指定的顶层文件
+func copyTree(src, dst, skip string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." || rel == skip {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+// copyFile 复制单个文件
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// dirSize 统计目录中文件的总大小
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
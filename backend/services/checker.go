@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cocode/backend/models"
+)
+
+// 特判程序的输入文件名（位于特判程序运行目录中）
+const (
+	checkerInputFile  = "input.txt"
+	checkerOutputFile = "output.txt"
+	checkerAnswerFile = "answer.txt"
+)
+
+// testlib 约定的特判程序退出码
+const (
+	testlibOK   = 0
+	testlibWA   = 1
+	testlibPE   = 2
+	testlibFail = 3
+)
+
+// defaultFloatEps 浮点比对未设置误差时使用的默认值
+const defaultFloatEps = 1e-6
+
+// Checker 输出检查器
+type Checker struct {
+	cfg   models.CheckerConfig
+	ctx   context.Context // 特判程序编译运行所属的上下文，取消和 CPU 计费随发起的运行
+	judge *build          // 特判程序，仅 special 模式
+}
+
+// ValidateCheckerConfig 校验检查器配置
+func ValidateCheckerConfig(cfg models.CheckerConfig) error {
+	switch cfg.Mode {
+	case "", models.CheckerExact, models.CheckerTolerant, models.CheckerIgnoreCase:
+		return nil
+	case models.CheckerFloat:
+		if cfg.AbsEps < 0 || cfg.RelEps < 0 {
+			return fmt.Errorf("误差不能为负数")
+		}
+		return nil
+	case models.CheckerSpecial:
+		if strings.TrimSpace(cfg.Code) == "" {
+			return fmt.Errorf("特判程序代码不能为空")
+		}
+		if _, err := GetLanguage(cfg.Language); err != nil {
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("不支持的比对方式: %s", cfg.Mode)
+}
+
+// NewChecker 根据配置创建检查器，special 模式下会先编译特判程序
+// 使用完毕后需调用 Close 释放特判程序的运行目录
+func NewChecker(ctx context.Context, cfg models.CheckerConfig) (*Checker, error) {
+	if err := ValidateCheckerConfig(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Mode == models.CheckerFloat && cfg.AbsEps == 0 && cfg.RelEps == 0 {
+		cfg.AbsEps, cfg.RelEps = defaultFloatEps, defaultFloatEps
+	}
+	c := &Checker{cfg: cfg, ctx: ctx}
+	if cfg.Mode != models.CheckerSpecial {
+		return c, nil
+	}
+
+	lang, _ := GetLanguage(cfg.Language)
+	b, result := compileSource(ctx, lang, cfg.Code)
+	if b == nil {
+		return nil, fmt.Errorf("特判程序%s", result.Message)
+	}
+	c.judge = b
+	return c, nil
+}
+
+// Close 释放检查器资源
+func (c *Checker) Close() {
+	if c.judge != nil {
+		c.judge.cleanup()
+		c.judge = nil
+	}
+}
+
+// Check 检查程序输出，返回 AC/WA（特判程序出错时为 FAIL）和说明
+func (c *Checker) Check(input, output, answer string) (string, string) {
+	var ok bool
+	var message string
+
+	switch c.cfg.Mode {
+	case models.CheckerExact:
+		ok, message = compareExact(output, answer)
+	case models.CheckerIgnoreCase:
+		ok, message = compareLines(strings.ToLower(output), strings.ToLower(answer))
+	case models.CheckerFloat:
+		ok, message = compareFloat(output, answer, c.cfg.AbsEps, c.cfg.RelEps)
+	case models.CheckerSpecial:
+		return c.runJudge(input, output, answer)
+	default:
+		ok, message = compareLines(output, answer)
+	}
+
+	if ok {
+		return models.VerdictAC, ""
+	}
+	return models.VerdictWA, message
+}
+
+// CheckResult 对运行成功的结果按检查器配置与标准答案比对，更新判定
+func CheckResult(ctx context.Context, result *models.CompileResult, cfg models.CheckerConfig, input, answer string) {
+	if result.Verdict != models.VerdictOK || answer == "" {
+		return
+	}
+
+	checker, err := NewChecker(ctx, cfg)
+	if err != nil {
+		result.Verdict = models.VerdictFail
+		result.Success = false
+		result.CheckMessage = err.Error()
+		result.Message += "\n检查器错误: " + err.Error()
+		return
+	}
+	defer checker.Close()
+
+	result.Verdict, result.CheckMessage = checker.Check(input, result.Output, answer)
+	result.Success = result.Verdict == models.VerdictAC
+	result.Message += "\n答案比对: " + result.Verdict
+	if result.CheckMessage != "" {
+		result.Message += " " + result.CheckMessage
+	}
+}
+
+// runJudge 运行特判程序: checker input output answer
+func (c *Checker) runJudge(input, output, answer string) (string, string) {
+	files := map[string]string{
+		checkerInputFile:  input,
+		checkerOutputFile: output,
+		checkerAnswerFile: answer,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(c.judge.dir, name), []byte(content), 0644); err != nil {
+			return models.VerdictFail, fmt.Sprintf("写入特判文件失败: %v", err)
+		}
+	}
+
+	outcome := c.judge.run(c.ctx, "", checkerInputFile, checkerOutputFile, checkerAnswerFile)
+	comment := strings.TrimSpace(outcome.Stderr)
+	if comment == "" {
+		comment = strings.TrimSpace(outcome.Stdout)
+	}
+	comment = truncate(comment, 200)
+
+	if outcome.Signal != "" || outcome.TimedOut || outcome.StartErr != nil || outcome.Cancelled {
+		return models.VerdictFail, "特判程序异常: " + describeOutcome(outcome)
+	}
+	switch outcome.ExitCode {
+	case testlibOK:
+		return models.VerdictAC, comment
+	case testlibWA:
+		return models.VerdictWA, comment
+	case testlibPE:
+		return models.VerdictWA, "格式错误: " + comment
+	case testlibFail:
+		return models.VerdictFail, "特判程序判定失败: " + comment
+	}
+	return models.VerdictFail, fmt.Sprintf("特判程序退出码 %d: %s", outcome.ExitCode, comment)
+}
+
+// compareExact 完全一致比对（仅统一换行符）
+func compareExact(actual, expected string) (bool, string) {
+	actual = strings.ReplaceAll(actual, "\r\n", "\n")
+	expected = strings.ReplaceAll(expected, "\r\n", "\n")
+	if actual == expected {
+		return true, ""
+	}
+	if strings.TrimRight(actual, " \t\n") == strings.TrimRight(expected, " \t\n") {
+		return false, "末尾空白不一致"
+	}
+	actualLines := strings.Split(actual, "\n")
+	expectedLines := strings.Split(expected, "\n")
+	return false, firstLineDiff(actualLines, expectedLines)
+}
+
+// compareLines 逐行比对输出，忽略行尾空白和末尾空行
+func compareLines(actual, expected string) (bool, string) {
+	actualLines := normalizeLines(actual)
+	expectedLines := normalizeLines(expected)
+	if diff := firstLineDiff(actualLines, expectedLines); diff != "" {
+		return false, diff
+	}
+	return true, ""
+}
+
+// firstLineDiff 返回第一处不一致的说明，完全一致时返回空串
+func firstLineDiff(actualLines, expectedLines []string) string {
+	for i := 0; i < len(actualLines) && i < len(expectedLines); i++ {
+		if actualLines[i] != expectedLines[i] {
+			return fmt.Sprintf("第 %d 行不一致: 期望 %q，实际 %q",
+				i+1, truncate(expectedLines[i], 80), truncate(actualLines[i], 80))
+		}
+	}
+	if len(actualLines) != len(expectedLines) {
+		return fmt.Sprintf("行数不一致: 期望 %d 行，实际 %d 行", len(expectedLines), len(actualLines))
+	}
+	return ""
+}
+
+// compareFloat 按空白分词比对，双方均为数字时允许绝对或相对误差
+func compareFloat(actual, expected string, absEps, relEps float64) (bool, string) {
+	actualTokens := strings.Fields(actual)
+	expectedTokens := strings.Fields(expected)
+
+	for i := 0; i < len(actualTokens) && i < len(expectedTokens); i++ {
+		a, b := actualTokens[i], expectedTokens[i]
+		if a == b {
+			continue
+		}
+		x, errA := strconv.ParseFloat(a, 64)
+		y, errB := strconv.ParseFloat(b, 64)
+		// nan、inf 只能与完全相同的文本匹配，否则误差为 NaN，任何比较都不成立
+		if errA != nil || errB != nil || !isFinite(x) || !isFinite(y) {
+			return false, fmt.Sprintf("第 %d 个数据不一致: 期望 %q，实际 %q", i+1, truncate(b, 80), truncate(a, 80))
+		}
+		diff := math.Abs(x - y)
+		if diff > absEps && diff > relEps*math.Abs(y) {
+			return false, fmt.Sprintf("第 %d 个数据误差过大: 期望 %s，实际 %s，误差 %g", i+1, b, a, diff)
+		}
+	}
+	if len(actualTokens) != len(expectedTokens) {
+		return false, fmt.Sprintf("数据个数不一致: 期望 %d 个，实际 %d 个", len(expectedTokens), len(actualTokens))
+	}
+	return true, ""
+}
+
+// isFinite 是否为有限的数
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+// normalizeLines 拆分为行并去掉行尾空白和末尾空行
+func normalizeLines(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// truncate 截断过长的字符串
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
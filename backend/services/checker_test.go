@@ -0,0 +1,34 @@
+package services
+
+import "testing"
+
+func TestCompareFloat(t *testing.T) {
+	tests := []struct {
+		name             string
+		actual, expected string
+		want             bool
+	}{
+		{"相同文本", "1 2.5 abc", "1 2.5 abc", true},
+		{"绝对误差内", "0.1000001", "0.1", true},
+		{"相对误差内", "1000000.5", "1000000", true},
+		{"误差过大", "0.11", "0.1", false},
+		{"非数字不一致", "abc", "abd", false},
+		{"数字与文本", "1", "one", false},
+		{"个数不一致", "1 2", "1 2 3", false},
+		{"末尾空白", "1\n2\n", "1 2", true},
+		{"nan 对数字", "nan", "1.5", false},
+		{"NaN 对 nan", "NaN", "nan", false},
+		{"nan 对 nan", "nan", "nan", true},
+		{"inf 对 Inf", "inf", "Inf", false},
+		{"inf 对 inf", "inf", "inf", true},
+		{"inf 对数字", "+Inf", "1e308", false},
+		{"数字对 nan", "1", "nan", false},
+		{"超出范围", "1e400", "1e308", false},
+	}
+	for _, tt := range tests {
+		ok, message := compareFloat(tt.actual, tt.expected, defaultFloatEps, defaultFloatEps)
+		if ok != tt.want {
+			t.Errorf("%s: compareFloat(%q, %q) = %v (%s)，期望 %v", tt.name, tt.actual, tt.expected, ok, message, tt.want)
+		}
+	}
+}
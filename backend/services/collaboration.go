@@ -1,6 +1,9 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -27,6 +30,11 @@ type CollaborationHub struct {
 	codeState      *models.CodeState
 	sharedState    *models.SharedState
 	compileRecords []models.CompileRecord
+	nextTestCaseID int
+	terminal       *TerminalSession      // 正在运行的终端会话
+	debugger       *DebugSession         // 正在进行的调试会话
+	runs           map[string]*RunHandle // 进行中的编译运行
+	nextRunID      int
 	mu             sync.RWMutex
 }
 
@@ -37,19 +45,20 @@ func NewCollaborationHub() *CollaborationHub {
 		broadcast:  make(chan []byte, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		codeState: &models.CodeState{
-			Code:    "// 欢迎使用协同编程平台\n// 在此编写你的C++代码\n\n#include <bits/stdc++.h>\nusing namespace std;\n\nint main() {\n    int n;\n    cin >> n;\n    cout << n*n << '\\n';\n    return 0;\n}\n",
-			Version: 0,
-			Updated: time.Now(),
-		},
+		codeState:  newCodeState(),
 		sharedState: &models.SharedState{
 			InputData:  "",
 			OutputData: "",
 			CompileLog: "等待编译...\n",
 			Answer:     "",
+			TestCases:  make([]models.TestCase, 0),
+			Checker:    models.CheckerConfig{Mode: models.CheckerTolerant},
 			Updated:    time.Now(),
 		},
 		compileRecords: make([]models.CompileRecord, 0),
+		nextTestCaseID: 1,
+		runs:           make(map[string]*RunHandle),
+		nextRunID:      1,
 	}
 }
 
@@ -85,27 +94,312 @@ func (h *CollaborationHub) Run() {
 	}
 }
 
-// GetCodeState 获取当前代码状态
-func (h *CollaborationHub) GetCodeState() *models.CodeState {
+// newCodeState 初始的工作区：只有主文件
+func newCodeState() *models.CodeState {
+	lang := DefaultLanguage()
+	code := "// 欢迎使用协同编程平台\n// 在此编写你的C++代码\n\n#include <bits/stdc++.h>\nusing namespace std;\n\nint main() {\n    int n;\n    cin >> n;\n    cout << n*n << '\\n';\n    return 0;\n}\n"
+	now := time.Now()
+	return &models.CodeState{
+		Code:     code,
+		Language: lang.Name,
+		Version:  0,
+		Updated:  now,
+		MainFile: lang.SourceFile,
+		Files:    []models.CodeFile{{Path: lang.SourceFile, Content: code, Updated: now}},
+	}
+}
+
+// CodeSnapshot 获取当前代码状态的副本
+func (h *CollaborationHub) CodeSnapshot() models.CodeState {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	return h.codeState
+	state := *h.codeState
+	state.Files = append([]models.CodeFile{}, h.codeState.Files...)
+	return state
 }
 
-// UpdateCodeState 更新代码状态
-func (h *CollaborationHub) UpdateCodeState(code string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.codeState.Code = code
+// findFileLocked 查找工作区中的文件，不存在时返回 -1（需持有锁）
+func (h *CollaborationHub) findFileLocked(path string) int {
+	for i, f := range h.codeState.Files {
+		if f.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkNewPathLocked 校验新文件路径，返回规范化后的路径（需持有锁）
+func (h *CollaborationHub) checkNewPathLocked(path string) (string, error) {
+	path, err := CleanFilePath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range h.codeState.Files {
+		if pathConflict(path, f.Path) {
+			return "", fmt.Errorf("文件 %s 已存在", f.Path)
+		}
+	}
+	return path, nil
+}
+
+// touchLocked 工作区发生变化（需持有锁）
+func (h *CollaborationHub) touchLocked() {
 	h.codeState.Version++
 	h.codeState.Updated = time.Now()
 }
 
+// GetFile 获取工作区中的文件，path 为空时为主文件
+func (h *CollaborationHub) GetFile(path string) (models.CodeFile, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if path == "" {
+		path = h.codeState.MainFile
+	}
+	i := h.findFileLocked(path)
+	if i < 0 {
+		return models.CodeFile{}, fmt.Errorf("文件 %s 不存在", path)
+	}
+	return h.codeState.Files[i], nil
+}
+
+// UpdateFile 更新文件内容，path 为空时更新主文件，返回更新后的文件和工作区版本号
+func (h *CollaborationHub) UpdateFile(path, content string) (models.CodeFile, int, error) {
+	return h.updateFile(path, -1, content)
+}
+
+// ReplaceFile 文件仍为 version 版本时替换其内容（格式化等服务器端修改），期间文件被修改时返回错误
+func (h *CollaborationHub) ReplaceFile(path string, version int, content string) (models.CodeFile, int, error) {
+	return h.updateFile(path, version, content)
+}
+
+// updateFile 更新文件内容，version 不为负时要求文件为该版本
+func (h *CollaborationHub) updateFile(path string, version int, content string) (models.CodeFile, int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if path == "" {
+		path = h.codeState.MainFile
+	}
+	i := h.findFileLocked(path)
+	if i < 0 {
+		return models.CodeFile{}, 0, fmt.Errorf("文件 %s 不存在", path)
+	}
+	f := &h.codeState.Files[i]
+	if version >= 0 && f.Version != version {
+		return models.CodeFile{}, 0, fmt.Errorf("文件 %s 已被修改，请重试", path)
+	}
+	f.Content = content
+	f.Version++
+	f.Updated = time.Now()
+	if path == h.codeState.MainFile {
+		h.codeState.Code = content
+	}
+	h.touchLocked()
+	return *f, h.codeState.Version, nil
+}
+
+// CreateFile 在工作区中新建文件
+func (h *CollaborationHub) CreateFile(path, content string) (models.CodeFile, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.codeState.Files) >= maxWorkspaceFiles {
+		return models.CodeFile{}, fmt.Errorf("工作区最多 %d 个文件", maxWorkspaceFiles)
+	}
+	path, err := h.checkNewPathLocked(path)
+	if err != nil {
+		return models.CodeFile{}, err
+	}
+	f := models.CodeFile{Path: path, Content: content, Updated: time.Now()}
+	h.codeState.Files = append(h.codeState.Files, f)
+	h.touchLocked()
+	return f, nil
+}
+
+// RenameFile 重命名工作区中的文件，主文件随语言确定，不能重命名
+func (h *CollaborationHub) RenameFile(path, newPath string) (models.CodeFile, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if path == h.codeState.MainFile {
+		return models.CodeFile{}, errors.New("主文件不能重命名")
+	}
+	i := h.findFileLocked(path)
+	if i < 0 {
+		return models.CodeFile{}, fmt.Errorf("文件 %s 不存在", path)
+	}
+	newPath, err := h.checkNewPathLocked(newPath)
+	if err != nil {
+		return models.CodeFile{}, err
+	}
+	f := &h.codeState.Files[i]
+	f.Path = newPath
+	f.Version++
+	f.Updated = time.Now()
+	h.touchLocked()
+	return *f, nil
+}
+
+// DeleteFile 删除工作区中的文件，主文件不能删除
+func (h *CollaborationHub) DeleteFile(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if path == h.codeState.MainFile {
+		return errors.New("主文件不能删除")
+	}
+	i := h.findFileLocked(path)
+	if i < 0 {
+		return fmt.Errorf("文件 %s 不存在", path)
+	}
+	h.codeState.Files = append(h.codeState.Files[:i], h.codeState.Files[i+1:]...)
+	h.touchLocked()
+	return nil
+}
+
+// UpdateLanguage 更新代码语言，主文件随之改名为新语言的源文件名，返回工作区版本号
+func (h *CollaborationHub) UpdateLanguage(lang *Language) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	main := h.findFileLocked(h.codeState.MainFile)
+	if lang.SourceFile != h.codeState.MainFile {
+		for _, f := range h.codeState.Files {
+			if f.Path != h.codeState.MainFile && pathConflict(lang.SourceFile, f.Path) {
+				return 0, fmt.Errorf("文件 %s 已存在，无法切换到 %s", f.Path, lang.DisplayName)
+			}
+		}
+		f := &h.codeState.Files[main]
+		f.Path = lang.SourceFile
+		f.Version++
+		f.Updated = time.Now()
+		h.codeState.MainFile = lang.SourceFile
+	}
+	h.codeState.Language = lang.Name
+	h.touchLocked()
+	return h.codeState.Version, nil
+}
+
 // BroadcastMessage 广播消息
 func (h *CollaborationHub) BroadcastMessage(message []byte) {
 	h.broadcast <- message
 }
 
+// SendToClient 向指定客户端发送消息（客户端已断开时忽略）
+func (h *CollaborationHub) SendToClient(client *Client, message []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	select {
+	case client.Send <- message:
+	default:
+	}
+}
+
+// StartRun 登记一次编译运行并分配运行编号
+func (h *CollaborationHub) StartRun(owner *Client, kind string) *RunHandle {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &RunHandle{
+		ID:      fmt.Sprintf("run-%d", h.nextRunID),
+		Kind:    kind,
+		Owner:   owner,
+		Started: time.Now(),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	h.nextRunID++
+	h.runs[run.ID] = run
+	return run
+}
+
+// FinishRun 移除已结束的编译运行
+func (h *CollaborationHub) FinishRun(run *RunHandle) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.runs, run.ID)
+	run.cancel()
+}
+
+// GetRun 获取进行中的编译运行，不存在或已结束时返回 nil
+func (h *CollaborationHub) GetRun(id string) *RunHandle {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.runs[id]
+}
+
+// AttachTerminal 登记终端会话，同一时间只允许一个终端会话
+func (h *CollaborationHub) AttachTerminal(session *TerminalSession) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.terminal != nil {
+		return false
+	}
+	h.terminal = session
+	return true
+}
+
+// DetachTerminal 移除终端会话
+func (h *CollaborationHub) DetachTerminal(session *TerminalSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.terminal == session {
+		h.terminal = nil
+	}
+}
+
+// GetTerminal 获取正在运行的终端会话，没有时返回 nil
+func (h *CollaborationHub) GetTerminal() *TerminalSession {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.terminal
+}
+
+// CloseClientTerminal 客户端断开时结束其发起的终端会话
+func (h *CollaborationHub) CloseClientTerminal(client *Client) {
+	h.mu.RLock()
+	session := h.terminal
+	h.mu.RUnlock()
+	if session != nil && session.Owner == client {
+		session.Close()
+	}
+}
+
+// AttachDebugger 登记调试会话，同一时间只允许一个调试会话
+func (h *CollaborationHub) AttachDebugger(session *DebugSession) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.debugger != nil {
+		return false
+	}
+	h.debugger = session
+	return true
+}
+
+// DetachDebugger 移除调试会话
+func (h *CollaborationHub) DetachDebugger(session *DebugSession) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.debugger == session {
+		h.debugger = nil
+	}
+}
+
+// GetDebugger 获取正在进行的调试会话，没有时返回 nil
+func (h *CollaborationHub) GetDebugger() *DebugSession {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.debugger
+}
+
+// CloseClientDebugger 客户端断开时结束其发起的调试会话
+func (h *CollaborationHub) CloseClientDebugger(client *Client) {
+	h.mu.RLock()
+	session := h.debugger
+	h.mu.RUnlock()
+	if session != nil && session.Owner == client {
+		session.Close()
+	}
+}
+
 // OnlineUser 在线用户信息
 type OnlineUser struct {
 	Username    string `json:"username"`
@@ -160,11 +454,11 @@ func (h *CollaborationHub) UpdateOutputData(output string) {
 	h.sharedState.Updated = time.Now()
 }
 
-// UpdateCompileLog 更新编译日志
-func (h *CollaborationHub) UpdateCompileLog(log string) {
+// AppendCompileLog 在编译日志末尾追加内容（在同一次加锁中完成，同时结束的运行不会丢失日志）
+func (h *CollaborationHub) AppendCompileLog(msg string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.sharedState.CompileLog = log
+	h.sharedState.CompileLog += msg
 	h.sharedState.Updated = time.Now()
 }
 
@@ -176,14 +470,111 @@ func (h *CollaborationHub) UpdateAnswer(answer string) {
 	h.sharedState.Updated = time.Now()
 }
 
+// UpdateChecker 更新输出检查器配置
+func (h *CollaborationHub) UpdateChecker(checker models.CheckerConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sharedState.Checker = checker
+	h.sharedState.Updated = time.Now()
+}
+
+// GetChecker 获取输出检查器配置
+func (h *CollaborationHub) GetChecker() models.CheckerConfig {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sharedState.Checker
+}
+
+// UpdateInteractor 更新交互器配置
+func (h *CollaborationHub) UpdateInteractor(interactor models.InteractorConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sharedState.Interactor = interactor
+	h.sharedState.Updated = time.Now()
+}
+
+// GetInteractor 获取交互器配置
+func (h *CollaborationHub) GetInteractor() models.InteractorConfig {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sharedState.Interactor
+}
+
+// UpdateFormatStyle 更新房间的代码格式化风格
+func (h *CollaborationHub) UpdateFormatStyle(style string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sharedState.FormatStyle = style
+	h.sharedState.Updated = time.Now()
+}
+
+// GetFormatStyle 获取房间的代码格式化风格，未设置时为配置中的默认风格
+func (h *CollaborationHub) GetFormatStyle() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return FormatStyle(h.sharedState.FormatStyle)
+}
+
+// SaveTestCase 保存测试用例，编号为 0 或不存在时新建
+func (h *CollaborationHub) SaveTestCase(tc models.TestCase) models.TestCase {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sharedState.Updated = time.Now()
+
+	for i := range h.sharedState.TestCases {
+		if tc.ID != 0 && h.sharedState.TestCases[i].ID == tc.ID {
+			h.sharedState.TestCases[i] = tc
+			return tc
+		}
+	}
+
+	tc.ID = h.nextTestCaseID
+	h.nextTestCaseID++
+	if tc.Name == "" {
+		tc.Name = fmt.Sprintf("样例 %d", tc.ID)
+	}
+	h.sharedState.TestCases = append(h.sharedState.TestCases, tc)
+	return tc
+}
+
+// DeleteTestCase 删除测试用例
+func (h *CollaborationHub) DeleteTestCase(id int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, tc := range h.sharedState.TestCases {
+		if tc.ID == id {
+			h.sharedState.TestCases = append(h.sharedState.TestCases[:i], h.sharedState.TestCases[i+1:]...)
+			h.sharedState.Updated = time.Now()
+			return true
+		}
+	}
+	return false
+}
+
+// GetTestCases 获取测试用例列表
+func (h *CollaborationHub) GetTestCases() []models.TestCase {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	cases := make([]models.TestCase, len(h.sharedState.TestCases))
+	copy(cases, h.sharedState.TestCases)
+	return cases
+}
+
 // AddCompileRecord 添加编译记录
-func (h *CollaborationHub) AddCompileRecord(username string, success bool) {
+func (h *CollaborationHub) AddCompileRecord(username string, lang *Language, result *models.CompileResult) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	record := models.CompileRecord{
 		Username:  username,
 		Timestamp: time.Now(),
-		Success:   success,
+		Success:   result.Success,
+		Language:  lang.Name,
+		Profile:   lang.ProfileName(),
+		Flags:     lang.Flags,
+		Verdict:   result.Verdict,
+		Stats:     result.Stats,
+		Cached:    result.Cached,
+		Benchmark: result.Benchmark,
 	}
 	h.compileRecords = append(h.compileRecords, record)
 
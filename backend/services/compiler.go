@@ -4,17 +4,39 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strings"
 	"time"
 
 	"cocode/backend/config"
 	"cocode/backend/models"
 )
 
-// CompileAndRun 编译并运行C++代码
-func CompileAndRun(code string, input string) *models.CompileResult {
+// 程序运行隔离后端
+const (
+	IsolationNone      = "none"      // 直接运行，仅做资源限制（开发环境）
+	IsolationNamespace = "namespace" // Linux 命名空间 + 私有根目录 + seccomp
+)
+
+// isolationBackend 返回配置的隔离后端，未配置时为 none
+func isolationBackend() string {
+	if config.AppConfig.Compiler.Isolation == "" {
+		return IsolationNone
+	}
+	return config.AppConfig.Compiler.Isolation
+}
+
+// build 一次编译的产物，位于独立的运行目录中
+type build struct {
+	lang *Language
+	dir  string // 运行目录，隔离模式下即程序的根目录
+}
+
+// compileSource 在新的运行目录中写入源代码并编译
+// 返回的结果中 Message 为编译日志；编译失败时 build 为 nil 且 Verdict 为 CE（被取消时为 CANCELLED）
+func compileSource(parent context.Context, lang *Language, code string) (*build, *models.CompileResult) {
 	result := &models.CompileResult{
 		Success: false,
 	}
@@ -23,87 +45,161 @@ func CompileAndRun(code string, input string) *models.CompileResult {
 	tempDir := config.AppConfig.Compiler.TempDir
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
 		result.Message = fmt.Sprintf("创建临时目录失败: %v", err)
-		return result
+		return nil, result
+	}
+
+	// 每次编译使用独立目录
+	runDir, err := os.MkdirTemp(tempDir, "run_")
+	if err != nil {
+		result.Message = fmt.Sprintf("创建运行目录失败: %v", err)
+		return nil, result
 	}
+	b := &build{lang: lang, dir: runDir}
 
-	// 创建临时源文件
-	timestamp := time.Now().UnixNano()
-	sourceFile := filepath.Join(tempDir, fmt.Sprintf("code_%d.cpp", timestamp))
-	execFile := filepath.Join(tempDir, fmt.Sprintf("exec_%d", timestamp))
-	inputFile := filepath.Join(tempDir, fmt.Sprintf("input_%d.txt", timestamp))
-	outputFile := filepath.Join(tempDir, fmt.Sprintf("output_%d.txt", timestamp))
-
-	// 清理临时文件
-	defer func() {
-		os.Remove(sourceFile)
-		os.Remove(execFile)
-		os.Remove(inputFile)
-		os.Remove(outputFile)
-	}()
-
-	// 写入源代码
-	if err := os.WriteFile(sourceFile, []byte(code), 0644); err != nil {
+	// 写入源代码和工作区中的其他文件
+	if err := writeSources(runDir, lang, code); err != nil {
+		b.cleanup()
 		result.Message = fmt.Sprintf("写入源文件失败: %v", err)
-		return result
+		return nil, result
 	}
 
-	// 写入输入数据
-	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
-		result.Message = fmt.Sprintf("写入输入文件失败: %v", err)
-		return result
+	if !lang.NeedsCompile() {
+		result.Message = "无需编译\n"
+		return b, result
+	}
+
+	// 相同的代码和编译参数直接使用缓存的编译产物
+	cache := getCompileCache()
+	cacheKey := ""
+	if cache.enabled() {
+		cacheKey = compileCacheKey(lang, code)
+		if meta, ok := cache.restore(cacheKey, runDir); ok {
+			result.Message = "编译成功!（使用编译缓存）\n" + meta.Output
+			result.Diagnostics = meta.Diagnostics
+			result.Cached = true
+			return b, result
+		}
 	}
 
 	// 编译代码
-	compileTimeout := time.Duration(config.AppConfig.Compiler.CompileTimeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), compileTimeout)
+	ctx, cancel := context.WithTimeout(parent, lang.CompileTimeout)
 	defer cancel()
 
-	compileArgs := append(config.AppConfig.Compiler.CompileFlags, sourceFile, "-o", execFile)
-	compileCmd := exec.CommandContext(ctx, config.AppConfig.Compiler.Compiler, compileArgs...)
+	compileArgs := lang.compileArgs()
+	compileCmd := exec.CommandContext(ctx, compileArgs[0], compileArgs[1:]...)
+	compileCmd.Dir = runDir
+	compileCmd.WaitDelay = time.Second
+	killGroupOnCancel(compileCmd)
 
 	var compileOut bytes.Buffer
 	var compileErr bytes.Buffer
 	compileCmd.Stdout = &compileOut
 	compileCmd.Stderr = &compileErr
 
-	if err := compileCmd.Run(); err != nil {
+	err = compileCmd.Run()
+	if state := compileCmd.ProcessState; state != nil {
+		chargeCPU(parent, state.UserTime()+state.SystemTime())
+	}
+	if err != nil {
+		defer b.cleanup()
+		if parent.Err() != nil {
+			result.Verdict = models.VerdictCancelled
+			result.Message = "编译已取消"
+			return nil, result
+		}
+		result.Verdict = models.VerdictCE
 		result.Message = fmt.Sprintf("编译失败:\n%s%s", compileOut.String(), compileErr.String())
-		return result
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Message += "\n编译超时!"
+			return nil, result
+		}
+		result.Diagnostics = parseTextDiagnostics(compileErr.String())
+		return nil, result
 	}
 
 	result.Message = "编译成功!\n" + compileOut.String()
+	result.Diagnostics = parseTextDiagnostics(compileErr.String())
+	if cacheKey != "" {
+		cache.store(cacheKey, runDir, lang.SourceFile, cacheMeta{
+			Output:      compileOut.String(),
+			Diagnostics: result.Diagnostics,
+		})
+	}
+	return b, result
+}
 
-	// 运行程序
-	runTimeout := time.Duration(config.AppConfig.Compiler.RunTimeout) * time.Second
-	runCtx, runCancel := context.WithTimeout(context.Background(), runTimeout)
-	defer runCancel()
+// command 生成运行编译产物的请求，args 追加在运行命令之后
+func (b *build) command(stdin io.Reader, args ...string) runRequest {
+	runArgs := append(b.lang.expandCommand(b.lang.RunCommand), args...)
+	env := b.lang.RunEnv
+	if b.lang.Coverage {
+		env = append(env[:len(env):len(env)], coverageEnv(b.dir)...)
+	}
+	return runRequest{
+		Path:    runArgs[0],
+		Args:    runArgs[1:],
+		Dir:     b.dir,
+		Stdin:   stdin,
+		Timeout: b.lang.RunTimeout,
+		Limits:  b.lang.Limits(),
+		Env:     env,
+	}
+}
 
-	runCmd := exec.CommandContext(runCtx, execFile)
+// run 以指定输入运行编译产物，ctx 取消时结束运行
+func (b *build) run(ctx context.Context, input string, args ...string) *runOutcome {
+	req := b.command(strings.NewReader(input), args...)
+	req.Ctx = ctx
+	return runSandboxed(req)
+}
 
-	// 设置输入
-	inputData, _ := os.ReadFile(inputFile)
-	runCmd.Stdin = bytes.NewReader(inputData)
+// cleanup 删除运行目录
+func (b *build) cleanup() {
+	os.RemoveAll(b.dir)
+}
 
-	var runOut bytes.Buffer
-	var runErr bytes.Buffer
-	runCmd.Stdout = &runOut
-	runCmd.Stderr = &runErr
+// RunOptions 编译运行的可选项
+type RunOptions struct {
+	OnOutput OutputFunc      // 运行中输出的回调，为空时不推送
+	Ctx      context.Context // 取消编译运行的上下文，为空时不可取消
+}
 
-	if err := runCmd.Run(); err != nil {
-		if runCtx.Err() == context.DeadlineExceeded {
-			result.Message += "\n运行超时!"
-			result.Output = runOut.String()
-			return result
-		}
-		result.Message += fmt.Sprintf("\n运行时错误:\n%s", runErr.String())
-		result.Output = runOut.String()
-		return result
+// context 返回取消编译运行的上下文
+func (o RunOptions) context() context.Context {
+	if o.Ctx == nil {
+		return context.Background()
 	}
+	return o.Ctx
+}
 
-	// 运行成功
-	result.Success = true
-	result.Message += "\n运行成功!"
-	result.Output = runOut.String()
+// CompileAndRun 使用指定语言的工具链编译并运行代码
+func CompileAndRun(lang *Language, code string, input string, opts RunOptions) *models.CompileResult {
+	b, result := compileSource(opts.context(), lang, code)
+	if b == nil {
+		return result
+	}
+	defer b.cleanup()
 
+	outcome := b.runInto(result, input, opts)
+	if b.lang.Coverage && !outcome.Cancelled {
+		b.collectCoverage(opts.context(), result)
+	}
 	return result
 }
+
+// runInto 运行编译产物，将运行结果写入 result
+func (b *build) runInto(result *models.CompileResult, input string, opts RunOptions) *runOutcome {
+	req := b.command(strings.NewReader(input))
+	req.OnOutput = opts.OnOutput
+	req.Ctx = opts.Ctx
+	outcome := runSandboxed(req)
+
+	result.Verdict = outcome.Verdict()
+	result.Signal = outcome.Signal
+	result.ExitCode = outcome.ExitCode
+	result.Output = outcome.Stdout
+	result.Stats = outcome.stats()
+	result.Message += "\n" + describeOutcome(outcome) + describeStats(result.Stats)
+	result.Success = result.Verdict == models.VerdictOK
+	return outcome
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// coverageFlags 覆盖率运行的编译参数，关闭优化使行执行次数与源代码对应
+var coverageFlags = []string{"--coverage", "-O0"}
+
+// gcovTimeout 统计覆盖率的超时
+const gcovTimeout = 10 * time.Second
+
+// CoverageLanguage 生成覆盖率运行使用的工具链：以 --coverage 编译，运行后收集 gcov 数据
+// 仅支持 GCC 编译的语言
+func CoverageLanguage(lang *Language) (*Language, error) {
+	if !lang.NeedsCompile() || !isGCC(lang.CompileCommand[0]) {
+		return nil, fmt.Errorf("%s 不支持覆盖率运行", lang.DisplayName)
+	}
+	l, err := lang.withFlags(coverageFlags)
+	if err != nil {
+		return nil, fmt.Errorf("%s 不支持覆盖率运行", lang.DisplayName)
+	}
+	l.Coverage = true
+	return l, nil
+}
+
+// gcovPath gcov 的路径
+func gcovPath() string {
+	if path := config.AppConfig.Compiler.Gcov; path != "" {
+		return path
+	}
+	return "gcov"
+}
+
+// coverageEnv 将程序写出的 .gcda 文件重定向到运行目录（工作目录）中
+// 编译产物中记录的是编译时运行目录下的绝对路径；各运行目录深度相同，使用编译缓存时同样适用
+func coverageEnv(dir string) []string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+	depth := strings.Count(filepath.ToSlash(abs), "/")
+	return []string{"GCOV_PREFIX=.", fmt.Sprintf("GCOV_PREFIX_STRIP=%d", depth)}
+}
+
+// gcovFile gcov JSON 输出中的一个源文件
+type gcovFile struct {
+	File  string `json:"file"`
+	Lines []struct {
+		LineNumber int   `json:"line_number"`
+		Count      int64 `json:"count"`
+		Branches   []struct {
+			Count int64 `json:"count"`
+		} `json:"branches"`
+	} `json:"lines"`
+}
+
+// coverage 用 gcov 统计运行目录中的覆盖率数据，只保留工作区中的文件
+// 未运行到的编译单元（没有 .gcda）按全部未执行统计
+func (b *build) coverage(ctx context.Context) (*models.CoverageReport, error) {
+	notes, _ := filepath.Glob(filepath.Join(b.dir, "*.gcno"))
+	if len(notes) == 0 {
+		return nil, fmt.Errorf("没有找到覆盖率数据")
+	}
+	args := []string{"--stdout", "--json-format", "--branch-probabilities"}
+	for _, n := range notes {
+		args = append(args, filepath.Base(n))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, gcovTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, gcovPath(), args...)
+	cmd.Dir = b.dir
+	killGroupOnCancel(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, lookErr := exec.LookPath(gcovPath()); lookErr != nil {
+			return nil, fmt.Errorf("服务器未安装 %s", gcovPath())
+		}
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// 同一文件（如头文件）可能出现在多个编译单元中，执行次数累加
+	lines := make(map[string]map[int]*models.LineCoverage)
+	dec := json.NewDecoder(&stdout)
+	for {
+		var doc struct {
+			Cwd   string     `json:"current_working_directory"`
+			Files []gcovFile `json:"files"`
+		}
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("解析 gcov 输出失败: %v", err)
+		}
+		for _, f := range doc.Files {
+			file, ok := workspacePath(f.File, doc.Cwd)
+			if !ok {
+				continue
+			}
+			if lines[file] == nil {
+				lines[file] = make(map[int]*models.LineCoverage)
+			}
+			for _, l := range f.Lines {
+				lc := lines[file][l.LineNumber]
+				if lc == nil {
+					lc = &models.LineCoverage{Line: l.LineNumber}
+					lines[file][l.LineNumber] = lc
+				}
+				lc.Count += l.Count
+				lc.Branches += len(l.Branches)
+				for _, br := range l.Branches {
+					if br.Count > 0 {
+						lc.Taken++
+					}
+				}
+			}
+		}
+	}
+
+	report := &models.CoverageReport{Files: []models.FileCoverage{}}
+	for file, byLine := range lines {
+		fc := models.FileCoverage{File: file, Lines: make([]models.LineCoverage, 0, len(byLine))}
+		for _, lc := range byLine {
+			fc.Lines = append(fc.Lines, *lc)
+			fc.Total++
+			if lc.Count > 0 {
+				fc.Covered++
+			}
+		}
+		sort.Slice(fc.Lines, func(i, j int) bool { return fc.Lines[i].Line < fc.Lines[j].Line })
+		report.Files = append(report.Files, fc)
+	}
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].File < report.Files[j].File })
+	return report, nil
+}
+
+// collectCoverage 覆盖率运行结束后统计覆盖率，写入结果和日志
+func (b *build) collectCoverage(ctx context.Context, result *models.CompileResult) {
+	report, err := b.coverage(ctx)
+	if err != nil {
+		result.Message += "\n\n覆盖率统计失败: " + err.Error()
+		return
+	}
+	result.Coverage = report
+	result.Message += "\n\n行覆盖率:"
+	for _, f := range report.Files {
+		percent := 0.0
+		if f.Total > 0 {
+			percent = float64(f.Covered) * 100 / float64(f.Total)
+		}
+		result.Message += fmt.Sprintf("\n  %s: %d/%d (%.1f%%)", f.File, f.Covered, f.Total, percent)
+	}
+}
@@ -0,0 +1,672 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 调试会话中程序的输入输出文件（位于运行目录中）
+const (
+	debugInputFile  = "debug_input.txt"
+	debugOutputFile = "debug_output.txt"
+)
+
+// 未配置 [debug] 时的默认值
+const (
+	defaultDebugSessionTimeout = 10 * time.Minute
+	defaultDebugIdleTimeout    = 2 * time.Minute
+	defaultDebugMemoryLimit    = 1024
+)
+
+const (
+	debugCommandTimeout = 10 * time.Second // 等待调试器响应单条命令的最长时间
+	debugExitTimeout    = 2 * time.Second  // 结束会话时等待调试器退出的最长时间
+	maxDebugFrames      = 32               // 调用栈最多显示的帧数
+	maxDebugLocals      = 64               // 最多显示的局部变量数
+	maxDebugValueLength = 512              // 变量值最多显示的字符数
+	maxDebugExpression  = 256              // 表达式的最大长度
+)
+
+// 调试命令
+const (
+	DebugBreak     = "break"     // 在指定行设置断点
+	DebugDelete    = "delete"    // 删除指定行的断点
+	DebugNext      = "next"      // 单步（不进入函数）
+	DebugStep      = "step"      // 单步（进入函数）
+	DebugFinish    = "finish"    // 运行到当前函数返回
+	DebugContinue  = "continue"  // 继续运行
+	DebugInterrupt = "interrupt" // 暂停运行中的程序
+	DebugPrint     = "print"     // 计算表达式
+	DebugBacktrace = "backtrace" // 调用栈
+	DebugLocals    = "locals"    // 局部变量
+)
+
+// 调试会话状态
+const (
+	DebugStarting = "starting"
+	DebugRunning  = "running"
+	DebugStopped  = "stopped"
+	DebugExited   = "exited"
+)
+
+// debugMotion 控制程序运行的命令对应的 MI 命令
+var debugMotion = map[string]string{
+	DebugNext:      "-exec-next",
+	DebugStep:      "-exec-step",
+	DebugFinish:    "-exec-finish",
+	DebugContinue:  "-exec-continue",
+	DebugInterrupt: "-exec-interrupt",
+}
+
+// debugFlags 调试编译参数
+var debugFlags = []string{"-g", "-O0"}
+
+// gdbPath 调试器路径
+func gdbPath() string {
+	if gdb := config.AppConfig.Debug.GDB; gdb != "" {
+		return gdb
+	}
+	return "gdb"
+}
+
+// CheckDebugger 检查服务器能否进行调试
+func CheckDebugger() error {
+	if isolationBackend() == IsolationNamespace {
+		return errors.New("当前隔离模式（namespace）不支持调试: 沙箱禁止调试器使用 ptrace")
+	}
+	if _, err := exec.LookPath(gdbPath()); err != nil {
+		return fmt.Errorf("服务器未安装调试器 %s，无法调试", gdbPath())
+	}
+	return nil
+}
+
+// DebugLanguage 生成调试使用的工具链：以 -g -O0 编译，仅支持 GCC 和 Clang 编译的语言
+func DebugLanguage(lang *Language) (*Language, error) {
+	if !lang.NeedsCompile() || !isGCCOrClang(lang.CompileCommand[0]) {
+		return nil, fmt.Errorf("%s 不支持调试", lang.DisplayName)
+	}
+	l, err := lang.withFlags(debugFlags)
+	if err != nil {
+		return nil, fmt.Errorf("%s 不支持调试", lang.DisplayName)
+	}
+	l.MemoryLimit = config.AppConfig.Debug.MemoryLimit
+	if l.MemoryLimit == 0 {
+		l.MemoryLimit = defaultDebugMemoryLimit
+	}
+	return l, nil
+}
+
+// debugSessionTimeout 调试会话最长持续时间
+func debugSessionTimeout() time.Duration {
+	if s := config.AppConfig.Debug.SessionTimeout; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultDebugSessionTimeout
+}
+
+// debugIdleTimeout 无人操作时结束会话的时间
+func debugIdleTimeout() time.Duration {
+	if s := config.AppConfig.Debug.IdleTimeout; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultDebugIdleTimeout
+}
+
+// DebugSession GDB 调试会话，通过 MI 接口控制调试器
+// 程序的标准输入来自共享输入，输出写入运行目录中的文件并在程序停止时推送
+type DebugSession struct {
+	Owner        *Client // 发起调试的客户端
+	ShareControl bool    // 是否允许房间内所有成员控制调试
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	idle    *time.Timer
+	idleOut atomic.Bool // 是否因空闲超时结束
+
+	mu          sync.Mutex
+	lang        *Language
+	stdin       io.Writer // 调试器的标准输入，会话运行期间有效
+	gdbDone     chan struct{}
+	nextToken   int
+	pending     map[int]chan miRecord
+	state       models.DebugState
+	breakpoints []models.DebugBreakpoint
+	outputSent  int // 已推送的程序输出字节数
+}
+
+// NewDebugSession 创建调试会话，ctx 取消时（如运行被取消）结束会话
+func NewDebugSession(ctx context.Context, owner *Client, shareControl bool) *DebugSession {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &DebugSession{
+		Owner:        owner,
+		ShareControl: shareControl,
+		ctx:          ctx,
+		cancel:       cancel,
+		nextToken:    1,
+		pending:      make(map[int]chan miRecord),
+		state:        models.DebugState{State: DebugStarting, Frames: []models.StackFrame{}, Locals: []models.DebugVariable{}},
+		breakpoints:  []models.DebugBreakpoint{},
+	}
+	// 空闲计时在调试器启动后开始
+	s.idle = time.AfterFunc(debugIdleTimeout(), func() {
+		s.idleOut.Store(true)
+		s.cancel()
+	})
+	s.idle.Stop()
+	return s
+}
+
+// CanControl 判断客户端是否可以控制调试
+func (s *DebugSession) CanControl(client *Client) bool {
+	return s.ShareControl || client.Username == s.Owner.Username
+}
+
+// Close 结束调试会话
+func (s *DebugSession) Close() {
+	s.cancel()
+}
+
+// State 当前的调试状态
+func (s *DebugSession) State() models.DebugState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state
+	st.Breakpoints = append([]models.DebugBreakpoint{}, s.breakpoints...)
+	return st
+}
+
+// Run 编译代码并在调试器中启动程序（停在 main 的第一行），阻塞直到会话结束
+// 程序启动后调用 onStart；每次程序停止或退出时调用 onState；程序输出通过 onOutput 推送
+func (s *DebugSession) Run(lang *Language, code, input string, breakpoints []int, onStart func(), onState func(models.DebugState), onOutput OutputFunc) *models.CompileResult {
+	defer s.cancel()
+	s.lang = lang
+
+	b, result := compileSource(s.ctx, lang, code)
+	if b == nil {
+		return result
+	}
+	defer b.cleanup()
+
+	if err := os.WriteFile(filepath.Join(b.dir, debugInputFile), []byte(input), 0644); err != nil {
+		result.Message += fmt.Sprintf("\n写入输入失败: %v", err)
+		return result
+	}
+
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		result.Message += fmt.Sprintf("\n创建管道失败: %v", err)
+		return result
+	}
+	defer inW.Close()
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		result.Message += fmt.Sprintf("\n创建管道失败: %v", err)
+		return result
+	}
+	defer outR.Close()
+
+	// 会话结束时先让调试器正常退出（同时结束被调试的程序），超时后再强制结束
+	gdbCtx, killGDB := context.WithCancel(context.WithoutCancel(s.ctx))
+	defer killGDB()
+
+	limits := lang.Limits()
+	limits.CPUTime = 0 // 调试器大部分时间在等待，由会话时长限制
+	req := runRequest{
+		Path:            gdbPath(),
+		Args:            []string{"--interpreter=mi2", "--nx", "--quiet", "./" + executableName},
+		Dir:             b.dir,
+		Stdin:           inR,
+		Stdout:          outW,
+		Timeout:         debugSessionTimeout(),
+		Limits:          limits,
+		Ctx:             gdbCtx,
+		closeAfterStart: []io.Closer{inR, outW},
+	}
+	outcomeCh := make(chan *runOutcome, 1)
+	go func() { outcomeCh <- runSandboxed(req) }()
+
+	events := make(chan miRecord, 16)
+	s.mu.Lock()
+	s.stdin = inW
+	s.gdbDone = make(chan struct{})
+	s.mu.Unlock()
+	go s.readMI(outR, events)
+
+	s.idle.Reset(debugIdleTimeout())
+	defer s.idle.Stop()
+
+	if err := s.setup(breakpoints); err != nil {
+		result.Verdict = models.VerdictRE
+		result.Message += "\n启动调试器失败: " + err.Error()
+	} else {
+		if onStart != nil {
+			onStart()
+		}
+		s.loop(b.dir, events, onState, onOutput)
+	}
+
+	// 结束调试器
+	s.mu.Lock()
+	fmt.Fprintf(inW, "-gdb-exit\n")
+	s.stdin = nil
+	s.mu.Unlock()
+	var outcome *runOutcome
+	select {
+	case outcome = <-outcomeCh:
+	case <-time.After(debugExitTimeout):
+		killGDB()
+		outcome = <-outcomeCh
+	}
+	s.flushOutput(b.dir, onOutput)
+
+	s.describeEnd(result, outcome)
+	return result
+}
+
+// setup 设置断点并启动程序
+func (s *DebugSession) setup(breakpoints []int) error {
+	commands := []string{
+		"-gdb-set confirm off",
+		"-gdb-set disable-randomization off",
+		"-gdb-set print elements 64",
+		fmt.Sprintf("-exec-arguments < %s > %s 2>&1", debugInputFile, debugOutputFile),
+	}
+	for _, cmd := range commands {
+		if _, err := s.exec(cmd); err != nil {
+			return err
+		}
+	}
+	for _, line := range breakpoints {
+		if _, err := s.insertBreakpoint(line); err != nil {
+			return err
+		}
+	}
+	_, err := s.exec("-exec-run --start")
+	return err
+}
+
+// loop 处理程序停止等事件，直到调试器退出或会话结束
+func (s *DebugSession) loop(dir string, events <-chan miRecord, onState func(models.DebugState), onOutput OutputFunc) {
+	ticker := time.NewTicker(streamInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case rec, ok := <-events:
+			if !ok {
+				return
+			}
+			switch rec.class {
+			case "running":
+				s.mu.Lock()
+				s.state = models.DebugState{State: DebugRunning, Frames: []models.StackFrame{}, Locals: []models.DebugVariable{}}
+				s.mu.Unlock()
+			case "stopped":
+				s.stopped(rec)
+			default:
+				continue
+			}
+			s.flushOutput(dir, onOutput)
+			st := s.State()
+			if onState != nil {
+				onState(st)
+			}
+			if st.State == DebugExited {
+				return
+			}
+		case <-ticker.C:
+			s.flushOutput(dir, onOutput)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// stopped 程序停止时更新状态，并读取调用栈和局部变量
+func (s *DebugSession) stopped(rec miRecord) {
+	reason := miString(rec.results, "reason")
+	st := models.DebugState{
+		State:  DebugStopped,
+		Reason: reason,
+		Frames: []models.StackFrame{},
+		Locals: []models.DebugVariable{},
+	}
+	switch reason {
+	case "exited-normally":
+		st.State = DebugExited
+	case "exited":
+		st.State = DebugExited
+		// MI 中的退出码为八进制
+		code, _ := strconv.ParseInt(miString(rec.results, "exit-code"), 8, 32)
+		st.ExitCode = int(code)
+	case "exited-signalled":
+		st.State = DebugExited
+		st.Signal = miString(rec.results, "signal-name")
+	default:
+		st.Signal = miString(rec.results, "signal-name")
+		if frame := miTuple(rec.results, "frame"); frame != nil {
+			st.Function = miString(frame, "func")
+			if file := miString(frame, "file"); s.lang.isUserSource(file) {
+				st.File, st.Line = strings.TrimPrefix(file, "./"), miInt(frame, "line")
+			}
+		}
+		if frames, err := s.frames(); err == nil {
+			st.Frames = frames
+		}
+		if locals, err := s.locals(); err == nil {
+			st.Locals = locals
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reason == "breakpoint-hit" {
+		number := miInt(rec.results, "bkptno")
+		for i := range s.breakpoints {
+			if s.breakpoints[i].Number == number {
+				s.breakpoints[i].Hits++
+			}
+		}
+	}
+	s.state = st
+}
+
+// Command 执行客户端发来的调试命令
+// print 返回表达式的值，backtrace/locals 返回调用栈/局部变量，break/delete 返回断点列表；
+// 控制运行的命令返回 nil，程序停止后的状态通过 Run 的 onState 推送
+func (s *DebugSession) Command(command string, line int, expression string) (interface{}, error) {
+	s.idle.Reset(debugIdleTimeout())
+
+	if mi, ok := debugMotion[command]; ok {
+		_, err := s.exec(mi)
+		return nil, err
+	}
+	switch command {
+	case DebugBreak:
+		return s.insertBreakpoint(line)
+	case DebugDelete:
+		return s.deleteBreakpoint(line)
+	case DebugPrint:
+		return s.evaluate(expression)
+	case DebugBacktrace:
+		return s.frames()
+	case DebugLocals:
+		return s.locals()
+	}
+	return nil, fmt.Errorf("不支持的调试命令: %s", command)
+}
+
+// insertBreakpoint 在源文件的指定行设置断点，返回断点列表
+func (s *DebugSession) insertBreakpoint(line int) ([]models.DebugBreakpoint, error) {
+	if line <= 0 {
+		return nil, errors.New("断点行号无效")
+	}
+	s.mu.Lock()
+	for _, bp := range s.breakpoints {
+		if bp.Line == line {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("第 %d 行已有断点", line)
+		}
+	}
+	s.mu.Unlock()
+
+	rec, err := s.exec(fmt.Sprintf("-break-insert %s:%d", s.lang.SourceFile, line))
+	if err != nil {
+		return nil, err
+	}
+	bkpt := miTuple(rec.results, "bkpt")
+	bp := models.DebugBreakpoint{Number: miInt(bkpt, "number"), Line: miInt(bkpt, "line")}
+	if bp.Line == 0 {
+		bp.Line = line
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakpoints = append(s.breakpoints, bp)
+	return append([]models.DebugBreakpoint{}, s.breakpoints...), nil
+}
+
+// deleteBreakpoint 删除指定行的断点，返回断点列表
+func (s *DebugSession) deleteBreakpoint(line int) ([]models.DebugBreakpoint, error) {
+	s.mu.Lock()
+	number := 0
+	for _, bp := range s.breakpoints {
+		if bp.Line == line {
+			number = bp.Number
+		}
+	}
+	s.mu.Unlock()
+	if number == 0 {
+		return nil, fmt.Errorf("第 %d 行没有断点", line)
+	}
+
+	if _, err := s.exec(fmt.Sprintf("-break-delete %d", number)); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.breakpoints[:0]
+	for _, bp := range s.breakpoints {
+		if bp.Number != number {
+			kept = append(kept, bp)
+		}
+	}
+	s.breakpoints = kept
+	return append([]models.DebugBreakpoint{}, s.breakpoints...), nil
+}
+
+// evaluate 计算表达式的值
+func (s *DebugSession) evaluate(expression string) (string, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return "", errors.New("表达式不能为空")
+	}
+	if len(expression) > maxDebugExpression || strings.ContainsAny(expression, "\r\n") {
+		return "", errors.New("表达式无效")
+	}
+	rec, err := s.exec("-data-evaluate-expression " + miQuote(expression))
+	if err != nil {
+		return "", err
+	}
+	return truncate(miString(rec.results, "value"), maxDebugValueLength), nil
+}
+
+// frames 读取调用栈
+func (s *DebugSession) frames() ([]models.StackFrame, error) {
+	rec, err := s.exec(fmt.Sprintf("-stack-list-frames 0 %d", maxDebugFrames-1))
+	if err != nil {
+		return nil, err
+	}
+	frames := []models.StackFrame{}
+	for _, item := range miList(rec.results, "stack") {
+		f, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		frame := models.StackFrame{
+			Index:    miInt(f, "level"),
+			Function: miString(f, "func"),
+			File:     miString(f, "file"),
+			Line:     miInt(f, "line"),
+		}
+		if frame.File == "" {
+			frame.File = miString(f, "from")
+		}
+		frame.User = s.lang.isUserSource(frame.File)
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// locals 读取当前栈帧的参数和局部变量，数组、结构体等复合类型单独求值
+func (s *DebugSession) locals() ([]models.DebugVariable, error) {
+	rec, err := s.exec("-stack-list-variables --simple-values")
+	if err != nil {
+		return nil, err
+	}
+	locals := []models.DebugVariable{}
+	for _, item := range miList(rec.results, "variables") {
+		v, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if len(locals) >= maxDebugLocals {
+			break
+		}
+		variable := models.DebugVariable{
+			Name: miString(v, "name"),
+			Type: miString(v, "type"),
+			Arg:  miString(v, "arg") == "1",
+		}
+		if value, ok := v["value"].(string); ok {
+			variable.Value = truncate(value, maxDebugValueLength)
+		} else if value, err := s.evaluate(variable.Name); err == nil {
+			variable.Value = value
+		}
+		locals = append(locals, variable)
+	}
+	return locals, nil
+}
+
+// exec 发送 MI 命令并等待结果，调试器返回错误时转为 error
+func (s *DebugSession) exec(command string) (miRecord, error) {
+	s.mu.Lock()
+	if s.stdin == nil {
+		s.mu.Unlock()
+		return miRecord{}, errors.New("调试器未运行")
+	}
+	token := s.nextToken
+	s.nextToken++
+	ch := make(chan miRecord, 1)
+	s.pending[token] = ch
+	_, err := fmt.Fprintf(s.stdin, "%d%s\n", token, command)
+	done := s.gdbDone
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, token)
+		s.mu.Unlock()
+	}()
+	if err != nil {
+		return miRecord{}, errors.New("调试器已退出")
+	}
+
+	select {
+	case rec := <-ch:
+		if rec.class == "error" {
+			return rec, errors.New(miString(rec.results, "msg"))
+		}
+		return rec, nil
+	case <-done:
+		return miRecord{}, errors.New("调试器已退出")
+	case <-time.After(debugCommandTimeout):
+		return miRecord{}, errors.New("调试器无响应")
+	}
+}
+
+// readMI 读取调试器输出：命令结果交给等待中的 exec，程序状态变化发送到 events
+func (s *DebugSession) readMI(r io.Reader, events chan<- miRecord) {
+	defer close(events)
+	defer func() {
+		s.mu.Lock()
+		close(s.gdbDone)
+		s.mu.Unlock()
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		rec, ok := parseMILine(scanner.Text())
+		if !ok {
+			continue
+		}
+		switch rec.kind {
+		case '^':
+			s.mu.Lock()
+			ch := s.pending[rec.token]
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- rec
+			}
+		case '*':
+			select {
+			case events <- rec:
+			case <-s.ctx.Done():
+			}
+		}
+	}
+}
+
+// flushOutput 推送程序新写入输出文件的内容
+func (s *DebugSession) flushOutput(dir string, onOutput OutputFunc) {
+	if onOutput == nil {
+		return
+	}
+	f, err := os.Open(filepath.Join(dir, debugOutputFile))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := f.Seek(int64(s.outputSent), io.SeekStart); err != nil {
+		return
+	}
+	data, _ := io.ReadAll(io.LimitReader(f, outputLimitBytes()))
+	if len(data) > 0 {
+		s.outputSent += len(data)
+		onOutput(StreamStdout, string(data))
+	}
+}
+
+// describeEnd 根据会话结束的原因填写结果
+func (s *DebugSession) describeEnd(result *models.CompileResult, outcome *runOutcome) {
+	st := s.State()
+	switch {
+	case result.Verdict != "":
+		// 启动失败
+		if outcome.StartErr != nil {
+			result.Message += "\n" + outcome.StartErr.Error()
+		}
+	case st.State == DebugExited:
+		result.ExitCode, result.Signal = st.ExitCode, st.Signal
+		result.Verdict = models.VerdictOK
+		if st.ExitCode != 0 || st.Signal != "" {
+			result.Verdict = models.VerdictRE
+		}
+		result.Message += "\n程序已结束: " + result.Verdict + " " + verdictText[result.Verdict]
+		if st.Signal != "" {
+			result.Message += " (信号 " + st.Signal + ")"
+		} else if st.ExitCode != 0 {
+			result.Message += fmt.Sprintf(" (退出码 %d)", st.ExitCode)
+		}
+	case s.idleOut.Load():
+		result.Verdict = models.VerdictCancelled
+		result.Message += fmt.Sprintf("\n调试会话空闲超过 %v，已结束", debugIdleTimeout())
+	case outcome.TimedOut:
+		result.Verdict = models.VerdictTLE
+		result.Message += fmt.Sprintf("\n调试会话超过最长时间 %v，已结束", debugSessionTimeout())
+	default:
+		result.Verdict = models.VerdictCancelled
+		result.Message += "\n调试已结束"
+	}
+	result.Success = result.Verdict == models.VerdictOK
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cocode/backend/models"
+)
+
+// maxDiagnostics 单次编译最多返回的诊断条数
+const maxDiagnostics = 200
+
+// 诊断级别
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityNote    = "note"
+)
+
+// runDiagnosticsCommand 运行诊断命令，返回其输出和是否以非零状态退出；超时或被取消时 ok 为 false
+func runDiagnosticsCommand(ctx context.Context, lang *Language, dir string) (output []byte, failed, ok bool) {
+	ctx, cancel := context.WithTimeout(ctx, lang.CompileTimeout)
+	defer cancel()
+
+	args := lang.diagnosticsArgs()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.WaitDelay = time.Second
+	killGroupOnCancel(cmd)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run() // 有错误时退出码非零，以输出为准
+	if state := cmd.ProcessState; state != nil {
+		chargeCPU(ctx, state.UserTime()+state.SystemTime())
+	}
+	if ctx.Err() != nil {
+		return nil, false, false
+	}
+	return out.Bytes(), err != nil, true
+}
+
+// parseDiagnostics 解析 GCC JSON 或 SARIF 格式的诊断输出
+func parseDiagnostics(data []byte) ([]models.Diagnostic, bool) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) == 0:
+		return nil, false
+	case data[0] == '[':
+		return parseGCCJSON(data)
+	case data[0] == '{':
+		return parseSARIF(data)
+	}
+	return nil, false
+}
+
+// gccLocation GCC JSON 诊断中的位置
+type gccLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// gccDiagnostic GCC -fdiagnostics-format=json 的诊断项
+type gccDiagnostic struct {
+	Kind      string `json:"kind"`
+	Message   string `json:"message"`
+	Option    string `json:"option"`
+	Locations []struct {
+		Caret  gccLocation  `json:"caret"`
+		Finish *gccLocation `json:"finish"`
+	} `json:"locations"`
+	Fixits []struct {
+		Start  gccLocation `json:"start"`
+		Next   gccLocation `json:"next"`
+		String string      `json:"string"`
+	} `json:"fixits"`
+	Children []gccDiagnostic `json:"children"`
+}
+
+// parseGCCJSON 解析 GCC JSON 诊断，子诊断（note）展开为独立条目
+// 有多个编译单元时 GCC 为每个单元输出一个数组
+func parseGCCJSON(data []byte) ([]models.Diagnostic, bool) {
+	var items []gccDiagnostic
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var unit []gccDiagnostic
+		if err := decoder.Decode(&unit); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, false
+		}
+		items = append(items, unit...)
+	}
+
+	diags := make([]models.Diagnostic, 0, len(items))
+	var add func(item gccDiagnostic)
+	add = func(item gccDiagnostic) {
+		d := models.Diagnostic{
+			Severity: normalizeSeverity(item.Kind),
+			Message:  item.Message,
+			Fixits:   []models.Fixit{},
+		}
+		if item.Option != "" {
+			d.Message += " [" + item.Option + "]"
+		}
+		if len(item.Locations) > 0 {
+			loc := item.Locations[0]
+			d.File, d.Line, d.Column = loc.Caret.File, loc.Caret.Line, loc.Caret.Column
+			if loc.Finish != nil {
+				// GCC 的 finish 指向范围内最后一个字符
+				d.EndLine, d.EndColumn = loc.Finish.Line, loc.Finish.Column+1
+			}
+		}
+		for _, f := range item.Fixits {
+			d.Fixits = append(d.Fixits, models.Fixit{
+				Line:        f.Start.Line,
+				Column:      f.Start.Column,
+				EndLine:     f.Next.Line,
+				EndColumn:   f.Next.Column,
+				Replacement: f.String,
+			})
+		}
+		diags = append(diags, d)
+		for _, child := range item.Children {
+			add(child)
+		}
+	}
+	for _, item := range items {
+		add(item)
+	}
+	return limitDiagnostics(diags), true
+}
+
+// sarifLog SARIF 2.1 日志中用到的部分
+type sarifLog struct {
+	Runs []struct {
+		Results []struct {
+			Level   string `json:"level"`
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+			Locations []struct {
+				PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+			} `json:"locations"`
+			Fixes []struct {
+				ArtifactChanges []struct {
+					Replacements []struct {
+						DeletedRegion   sarifRegion `json:"deletedRegion"`
+						InsertedContent struct {
+							Text string `json:"text"`
+						} `json:"insertedContent"`
+					} `json:"replacements"`
+				} `json:"artifactChanges"`
+			} `json:"fixes"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation struct {
+		URI string `json:"uri"`
+	} `json:"artifactLocation"`
+	Region sarifRegion `json:"region"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// parseSARIF 解析 SARIF 格式的诊断（Clang -fdiagnostics-format=sarif）
+func parseSARIF(data []byte) ([]models.Diagnostic, bool) {
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil || len(log.Runs) == 0 {
+		return nil, false
+	}
+
+	var diags []models.Diagnostic
+	for _, run := range log.Runs {
+		for _, r := range run.Results {
+			d := models.Diagnostic{
+				Severity: normalizeSeverity(r.Level),
+				Message:  r.Message.Text,
+				Fixits:   []models.Fixit{},
+			}
+			if len(r.Locations) > 0 {
+				loc := r.Locations[0].PhysicalLocation
+				d.File = strings.TrimPrefix(loc.ArtifactLocation.URI, "file://")
+				d.Line, d.Column = loc.Region.StartLine, loc.Region.StartColumn
+				d.EndLine, d.EndColumn = loc.Region.EndLine, loc.Region.EndColumn
+			}
+			for _, fix := range r.Fixes {
+				for _, change := range fix.ArtifactChanges {
+					for _, rep := range change.Replacements {
+						d.Fixits = append(d.Fixits, models.Fixit{
+							Line:        rep.DeletedRegion.StartLine,
+							Column:      rep.DeletedRegion.StartColumn,
+							EndLine:     rep.DeletedRegion.EndLine,
+							EndColumn:   rep.DeletedRegion.EndColumn,
+							Replacement: rep.InsertedContent.Text,
+						})
+					}
+				}
+			}
+			diags = append(diags, d)
+		}
+	}
+	return limitDiagnostics(diags), true
+}
+
+// textDiagnosticPattern GCC/Clang 文本诊断行: file:line[:column]: severity: message
+var textDiagnosticPattern = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)? (fatal error|error|warning|note|remark): (.*)$`)
+
+// locationOnlyPattern 没有级别的定位行（如 Go 编译器: ./main.go:5:2: message）
+var locationOnlyPattern = regexp.MustCompile(`^(.+?):(\d+):(\d+): (.*)$`)
+
+// parseTextDiagnostics 解析编译器的文本输出
+// 没有任何带级别的诊断时，将定位行视为错误（Go 等编译器的输出格式）
+func parseTextDiagnostics(output string) []models.Diagnostic {
+	var diags, located []models.Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := textDiagnosticPattern.FindStringSubmatch(line); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			diags = append(diags, models.Diagnostic{
+				File:     m[1],
+				Line:     lineNo,
+				Column:   column,
+				Severity: normalizeSeverity(m[4]),
+				Message:  m[5],
+				Fixits:   []models.Fixit{},
+			})
+			continue
+		}
+		if m := locationOnlyPattern.FindStringSubmatch(line); m != nil {
+			lineNo, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			located = append(located, models.Diagnostic{
+				File:     strings.TrimPrefix(m[1], "./"),
+				Line:     lineNo,
+				Column:   column,
+				Severity: SeverityError,
+				Message:  m[4],
+				Fixits:   []models.Fixit{},
+			})
+		}
+	}
+	if len(diags) == 0 {
+		diags = located
+	}
+	return limitDiagnostics(diags)
+}
+
+// normalizeSeverity 统一诊断级别为 error、warning、note
+func normalizeSeverity(kind string) string {
+	switch kind {
+	case "error", "fatal error", "fatal":
+		return SeverityError
+	case "warning":
+		return SeverityWarning
+	}
+	return SeverityNote
+}
+
+// limitDiagnostics 限制诊断条数
+func limitDiagnostics(diags []models.Diagnostic) []models.Diagnostic {
+	if len(diags) > maxDiagnostics {
+		return diags[:maxDiagnostics]
+	}
+	return diags
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+)
+
+// 未配置 [format] 时的默认值
+const (
+	defaultFormatStyle   = "Google"
+	defaultFormatTimeout = 5 * time.Second
+)
+
+// maxFormatStyleLength 内联格式化风格的最大长度
+const maxFormatStyleLength = 512
+
+// formatStyles clang-format 的预定义风格
+var formatStyles = []string{"LLVM", "Google", "Chromium", "Mozilla", "WebKit", "Microsoft", "GNU"}
+
+// formatExts clang-format 支持的源文件扩展名
+var formatExts = map[string]bool{
+	".c": true, ".h": true, ".cpp": true, ".cc": true, ".cxx": true,
+	".hpp": true, ".hh": true, ".hxx": true, ".java": true, ".js": true,
+	".cs": true, ".proto": true,
+}
+
+// clangFormatPath 格式化工具路径
+func clangFormatPath() string {
+	if path := config.AppConfig.Format.ClangFormat; path != "" {
+		return path
+	}
+	return "clang-format"
+}
+
+// formatTimeout 单次格式化的超时
+func formatTimeout() time.Duration {
+	if s := config.AppConfig.Format.Timeout; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultFormatTimeout
+}
+
+// FormatStyle 房间使用的格式化风格，未设置时为配置中的默认风格
+func FormatStyle(roomStyle string) string {
+	if roomStyle != "" {
+		return roomStyle
+	}
+	if style := config.AppConfig.Format.Style; style != "" {
+		return style
+	}
+	return defaultFormatStyle
+}
+
+// CheckFormatStyle 校验格式化风格：预定义风格（不区分大小写）或 {key: value, ...} 形式的内联配置
+// 不允许从文件读取配置
+func CheckFormatStyle(style string) (string, error) {
+	style = strings.TrimSpace(style)
+	for _, s := range formatStyles {
+		if strings.EqualFold(style, s) {
+			return s, nil
+		}
+	}
+	if strings.HasPrefix(style, "{") && strings.HasSuffix(style, "}") {
+		if len(style) > maxFormatStyleLength || strings.ContainsAny(style, "\r\n") {
+			return "", errors.New("格式化风格配置过长或包含换行")
+		}
+		if strings.Contains(style, "InheritParentConfig") {
+			return "", errors.New("格式化风格不能继承配置文件")
+		}
+		return style, nil
+	}
+	return "", fmt.Errorf("不支持的格式化风格: %s（可选 %s，或 {BasedOnStyle: Google, IndentWidth: 4} 形式的配置）",
+		style, strings.Join(formatStyles, "、"))
+}
+
+// FormatCode 用 clang-format 格式化文件内容，startLine、endLine 为 0 时格式化整个文件，
+// 否则只格式化 [startLine, endLine] 行（从 1 开始）
+func FormatCode(path, content, style string, startLine, endLine int) (string, error) {
+	if !formatExts[filepath.Ext(path)] {
+		return "", fmt.Errorf("不支持格式化 %s 文件", path)
+	}
+	tool, err := exec.LookPath(clangFormatPath())
+	if err != nil {
+		return "", fmt.Errorf("服务器未安装 %s，无法格式化代码", clangFormatPath())
+	}
+
+	args := []string{"--style=" + style, "--assume-filename=" + path}
+	if startLine != 0 || endLine != 0 {
+		lines := strings.Count(content, "\n") + 1
+		if startLine < 1 || endLine < startLine || startLine > lines {
+			return "", errors.New("格式化的行范围无效")
+		}
+		if endLine > lines {
+			endLine = lines
+		}
+		args = append(args, fmt.Sprintf("--lines=%d:%d", startLine, endLine))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), formatTimeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, tool, args...)
+	cmd.Stdin = strings.NewReader(content)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", errors.New("格式化超时")
+		}
+		return "", fmt.Errorf("格式化失败: %s", strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 模糊测试结果状态
+const (
+	FuzzFound     = "found"     // 发现崩溃
+	FuzzPassed    = "passed"    // 达到时间上限，未发现崩溃
+	FuzzError     = "error"     // 编译失败或模糊测试无法运行
+	FuzzCancelled = "cancelled" // 被取消
+)
+
+// 未配置 [fuzz] 时的默认值
+const (
+	defaultFuzzTimeLimit    = 60 * time.Second
+	defaultFuzzMinimizeTime = 10 * time.Second
+	defaultFuzzRSSLimit     = 2048
+	defaultFuzzMaxLen       = 4096
+)
+
+const (
+	fuzzEntryPoint = "LLVMFuzzerTestOneInput" // 入口文件中必须定义的函数
+	fuzzCorpusDir  = "corpus"                 // 运行目录中的语料目录
+	fuzzCrashDir   = "crashes"                // 运行目录中保存崩溃输入的目录
+	fuzzSlack      = 10 * time.Second         // 超出 -max_total_time 的等待时间（启动、输出崩溃报告）
+	maxFuzzCrashes = 5                        // 最多收集的崩溃数
+	maxFuzzSeeds   = 64                       // 最多使用的初始语料数
+)
+
+// fuzzFlags 模糊测试的编译参数
+var fuzzFlags = []string{"-fsanitize=fuzzer,address", "-g", "-O1", "-fno-omit-frame-pointer"}
+
+// fuzzCrashKinds libFuzzer 保存的输入文件名前缀（即崩溃类型）
+var fuzzCrashKinds = []string{"crash", "timeout", "oom", "leak"}
+
+var (
+	// fuzzExecutionsPattern -print_final_stats 输出中的执行次数
+	fuzzExecutionsPattern = regexp.MustCompile(`stat::number_of_executed_units:\s*(\d+)`)
+	// fuzzArtifactPattern artifact_prefix='crashes/'; Test unit written to crashes/crash-<sha1>
+	fuzzArtifactPattern = regexp.MustCompile(`Test unit written to (\S+)`)
+)
+
+// FuzzOptions 模糊测试选项
+type FuzzOptions struct {
+	Duration time.Duration   // 运行时间，0 或超过配置上限时使用上限
+	Seeds    []string        // 初始语料（如测试用例的输入），可为空
+	Ctx      context.Context // 取消模糊测试的上下文，为空时不可取消
+}
+
+// fuzzCompiler 模糊测试使用的编译器
+func fuzzCompiler(lang *Language) string {
+	cfg := config.AppConfig.Fuzz
+	if lang.Extension() == ".c" {
+		if cfg.ClangC != "" {
+			return cfg.ClangC
+		}
+		return "clang"
+	}
+	if cfg.Clang != "" {
+		return cfg.Clang
+	}
+	return "clang++"
+}
+
+// fuzzTimeLimit 本次模糊测试的运行时间
+func fuzzTimeLimit(requested time.Duration) time.Duration {
+	limit := defaultFuzzTimeLimit
+	if s := config.AppConfig.Fuzz.TimeLimit; s > 0 {
+		limit = time.Duration(s) * time.Second
+	}
+	if requested > 0 && requested < limit {
+		return requested
+	}
+	return limit
+}
+
+// fuzzMinimizeTime 每个崩溃输入最小化的时间
+func fuzzMinimizeTime() time.Duration {
+	if s := config.AppConfig.Fuzz.MinimizeTime; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultFuzzMinimizeTime
+}
+
+// FuzzLanguage 生成模糊测试使用的工具链：以 Clang 和 -fsanitize=fuzzer,address 编译入口文件和工作区中的其他编译单元
+// 主文件定义了 main，不参与编译（入口文件可以在重命名 main 后 #include 主文件）；lang 应已附带工作区文件
+func FuzzLanguage(lang *Language, harness string) (*Language, error) {
+	if !lang.NeedsCompile() || !isGCCOrClang(lang.CompileCommand[0]) {
+		return nil, fmt.Errorf("%s 不支持模糊测试", lang.DisplayName)
+	}
+	if harness == "" {
+		return nil, fmt.Errorf("请指定模糊测试的入口文件（定义 %s 的源文件）", fuzzEntryPoint)
+	}
+	var units []string
+	found := false
+	for _, unit := range lang.translationUnits() {
+		if unit == harness {
+			found = true
+		} else {
+			units = append(units, unit)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s 不是可作为模糊测试入口的源文件（不能是主文件）", harness)
+	}
+	for _, f := range lang.Files {
+		if f.Path == harness && !strings.Contains(f.Content, fuzzEntryPoint) {
+			return nil, fmt.Errorf("%s 中没有定义 %s", harness, fuzzEntryPoint)
+		}
+	}
+
+	compiler := fuzzCompiler(lang)
+	if _, err := exec.LookPath(compiler); err != nil {
+		return nil, fmt.Errorf("服务器未安装 %s，无法进行模糊测试", compiler)
+	}
+
+	// 编译命令中不再有 {src}：入口文件代替主文件，其余编译单元直接列出
+	var command []string
+	for i, arg := range lang.CompileCommand {
+		if arg == placeholderSrc {
+			command = append([]string{compiler}, lang.CompileCommand[1:i]...)
+			command = append(command, fuzzFlags...)
+			command = append(command, harness)
+			command = append(command, units...)
+			command = append(command, lang.CompileCommand[i+1:]...)
+			break
+		}
+	}
+	if command == nil {
+		return nil, fmt.Errorf("%s 不支持模糊测试", lang.DisplayName)
+	}
+
+	l := *lang
+	l.CompileCommand = command
+	l.DiagnosticsCommand = nil
+	l.Flags = append(append([]string{}, lang.Flags...), fuzzFlags...)
+	// AddressSanitizer 需要预留大量虚拟地址空间，内存由 -rss_limit_mb 限制
+	l.MemoryLimit = -1
+	l.RunEnv = append(append([]string{}, lang.RunEnv...), sanitizerEnv...)
+	return &l, nil
+}
+
+// RunFuzz 模糊测试：编译入口文件后用 libFuzzer 运行到时间上限，每发现一个崩溃输入就最小化并记录，
+// 之后继续模糊测试，直到再次发现相同的崩溃或收集到 maxFuzzCrashes 个
+// lang 应为 FuzzLanguage 返回的工具链，harness 为入口文件
+func RunFuzz(lang *Language, code, harness string, opts FuzzOptions) *models.FuzzResult {
+	ctx := opts.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := &models.FuzzResult{Harness: harness, Crashes: []models.FuzzCrash{}}
+
+	b, compiled := compileSource(ctx, lang, code)
+	if b == nil {
+		result.Status = FuzzError
+		if compiled.Verdict == models.VerdictCancelled {
+			result.Status = FuzzCancelled
+		}
+		result.Message = compiled.Message
+		return result
+	}
+	defer b.cleanup()
+
+	for _, dir := range []string{fuzzCorpusDir, fuzzCrashDir} {
+		if err := os.MkdirAll(filepath.Join(b.dir, dir), 0755); err != nil {
+			result.Status, result.Message = FuzzError, fmt.Sprintf("创建语料目录失败: %v", err)
+			return result
+		}
+	}
+	for i, seed := range opts.Seeds {
+		if i >= maxFuzzSeeds {
+			break
+		}
+		os.WriteFile(filepath.Join(b.dir, fuzzCorpusDir, fmt.Sprintf("seed_%d", i)), []byte(seed), 0644)
+	}
+
+	limit := fuzzTimeLimit(opts.Duration)
+	start := time.Now()
+	fuzzed := time.Duration(0) // 模糊测试的运行时间，不含最小化
+	finish := func(status, message string) *models.FuzzResult {
+		result.Status = status
+		result.Elapsed = time.Since(start).Milliseconds()
+		result.Message = message
+		return result
+	}
+
+	processed := make(map[string]bool)
+	signatures := make(map[string]bool)
+	for len(result.Crashes) < maxFuzzCrashes {
+		remaining := limit - fuzzed
+		if remaining < time.Second {
+			break
+		}
+		outcome := b.fuzz(ctx, remaining)
+		fuzzed += outcome.WallTime
+		if m := fuzzExecutionsPattern.FindStringSubmatch(outcome.Stderr); m != nil {
+			n, _ := strconv.ParseInt(m[1], 10, 64)
+			result.Executions += n
+		}
+		if outcome.Cancelled {
+			return finish(FuzzCancelled, fmt.Sprintf("模糊测试已取消: 已执行 %d 个输入", result.Executions))
+		}
+
+		artifact := fuzzArtifact(outcome.Stderr)
+		if artifact == "" {
+			if outcome.StartErr != nil || outcome.OutputExceeded || (outcome.ExitCode != 0 && !outcome.TimedOut) {
+				return finish(FuzzError, "模糊测试运行失败\n"+describeOutcome(outcome)+"\n"+
+					truncate(strings.TrimSpace(outcome.Stderr), 2000))
+			}
+			break
+		}
+		// 崩溃输入以内容的哈希命名，同名即为再次找到了同一个输入
+		if processed[artifact] {
+			break
+		}
+		processed[artifact] = true
+
+		crash := b.fuzzCrash(ctx, artifact, outcome.Stderr)
+		signature := crash.Kind
+		if crash.Report != nil {
+			signature += fmt.Sprintf(" %s %s:%d", crash.Report.Kind, crash.Report.File, crash.Report.Line)
+		}
+		// libFuzzer 发现崩溃后即退出，再次发现同一崩溃说明难以找到新的问题
+		if signatures[signature] {
+			break
+		}
+		signatures[signature] = true
+		result.Crashes = append(result.Crashes, crash)
+	}
+
+	if len(result.Crashes) == 0 {
+		return finish(FuzzPassed, fmt.Sprintf("运行 %v，执行 %d 个输入，未发现崩溃", limit, result.Executions))
+	}
+	message := fmt.Sprintf("执行 %d 个输入，发现 %d 个崩溃:", result.Executions, len(result.Crashes))
+	for _, c := range result.Crashes {
+		message += fmt.Sprintf("\n  %s（%d 字节", c.Kind, c.Size)
+		if c.Minimized {
+			message += "，已最小化"
+		}
+		message += "）"
+		if c.Report != nil {
+			message += " " + c.Report.Kind
+			if c.Report.File != "" {
+				message += fmt.Sprintf(" (%s:%d)", c.Report.File, c.Report.Line)
+			}
+		}
+	}
+	return finish(FuzzFound, message)
+}
+
+// fuzzRequest 以指定的 libFuzzer 参数运行模糊测试程序，运行时间为 duration
+func (b *build) fuzzRequest(ctx context.Context, duration time.Duration, args ...string) runRequest {
+	rssLimit := config.AppConfig.Fuzz.RSSLimit
+	if rssLimit <= 0 {
+		rssLimit = defaultFuzzRSSLimit
+	}
+	unitTimeout := int(b.lang.RunTimeout.Seconds())
+	if unitTimeout < 1 {
+		unitTimeout = 1
+	}
+	args = append([]string{
+		fmt.Sprintf("-max_total_time=%d", int(duration.Seconds())),
+		fmt.Sprintf("-timeout=%d", unitTimeout),
+		fmt.Sprintf("-rss_limit_mb=%d", rssLimit),
+		"-close_fd_mask=1", // 丢弃被测代码的标准输出
+	}, args...)
+	req := b.command(strings.NewReader(""), args...)
+	req.Ctx = ctx
+	req.Timeout = duration + fuzzSlack
+	req.Limits.CPUTime = int(req.Timeout.Seconds())
+	return req
+}
+
+// fuzz 在语料目录上运行 libFuzzer，发现崩溃时将输入保存到 fuzzCrashDir 并退出
+func (b *build) fuzz(ctx context.Context, duration time.Duration) *runOutcome {
+	maxLen := config.AppConfig.Fuzz.MaxLen
+	if maxLen <= 0 {
+		maxLen = defaultFuzzMaxLen
+	}
+	return runSandboxed(b.fuzzRequest(ctx, duration,
+		fmt.Sprintf("-max_len=%d", maxLen),
+		"-artifact_prefix="+fuzzCrashDir+"/",
+		"-verbosity=0",
+		"-print_final_stats=1",
+		fuzzCorpusDir,
+	))
+}
+
+// fuzzArtifact libFuzzer 输出中保存的崩溃输入（相对运行目录的路径），没有时返回空
+func fuzzArtifact(stderr string) string {
+	m := fuzzArtifactPattern.FindStringSubmatch(stderr)
+	if m == nil {
+		return ""
+	}
+	path := filepath.Clean(m[1])
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "..") || fuzzCrashKind(filepath.Base(path)) == "" {
+		return ""
+	}
+	return path
+}
+
+// fuzzCrashKind 根据 libFuzzer 保存的文件名判断崩溃类型
+func fuzzCrashKind(name string) string {
+	for _, kind := range fuzzCrashKinds {
+		if strings.HasPrefix(name, kind+"-") {
+			return kind
+		}
+	}
+	return ""
+}
+
+// fuzzCrash 解析崩溃报告，最小化崩溃输入
+func (b *build) fuzzCrash(ctx context.Context, artifact, stderr string) models.FuzzCrash {
+	crash := models.FuzzCrash{Kind: fuzzCrashKind(filepath.Base(artifact))}
+
+	stripDir := runDirStripper()
+	reports := parseSanitizerReports(stripDir(stderr), b.lang)
+	symbolizeFrames(ctx, reports, filepath.Join(b.dir, executableName), b.lang, stripDir)
+	locateReports(reports)
+	if len(reports) > 0 {
+		crash.Report = &reports[0]
+	}
+
+	input, err := os.ReadFile(filepath.Join(b.dir, artifact))
+	if err != nil {
+		return crash
+	}
+	if crash.Kind == "crash" {
+		if minimized, ok := b.minimizeCrash(ctx, artifact); ok && len(minimized) < len(input) {
+			input, crash.Minimized = minimized, true
+		}
+	}
+	crash.Size = len(input)
+	crash.InputBase64 = base64.StdEncoding.EncodeToString(input)
+	if utf8.Valid(input) {
+		crash.Input = string(input)
+	}
+	return crash
+}
+
+// minimizeCrash 用 libFuzzer 的 -minimize_crash 缩小崩溃输入，失败时返回 false
+func (b *build) minimizeCrash(ctx context.Context, artifact string) ([]byte, bool) {
+	// 不能放在 fuzzCrashDir 中，否则会被当作新的崩溃输入
+	minimized := filepath.Base(artifact) + ".min"
+	outcome := runSandboxed(b.fuzzRequest(ctx, fuzzMinimizeTime(),
+		"-minimize_crash=1",
+		"-exact_artifact_path="+minimized,
+		artifact,
+	))
+	if outcome.Cancelled || outcome.StartErr != nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(b.dir, minimized))
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+)
+
+// miRecord GDB/MI 输出中的一条记录
+type miRecord struct {
+	token   int                    // 命令编号，没有时为 -1
+	kind    byte                   // ^ 结果、* 执行状态、= 通知、~ @ & 流输出
+	class   string                 // done、running、error、stopped 等
+	results map[string]interface{} // 结果中的键值（值为 string、map 或 []interface{}）
+	text    string                 // 流输出的内容
+}
+
+// parseMILine 解析一行 MI 输出，提示符 (gdb) 和无法识别的行返回 false
+func parseMILine(line string) (miRecord, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	rec := miRecord{token: -1}
+
+	i := 0
+	for i < len(line) && line[i] >= '0' && line[i] <= '9' {
+		i++
+	}
+	if i > 0 {
+		rec.token, _ = strconv.Atoi(line[:i])
+	}
+	if i >= len(line) {
+		return rec, false
+	}
+
+	rec.kind = line[i]
+	rest := line[i+1:]
+	switch rec.kind {
+	case '~', '@', '&':
+		p := &miParser{s: rest}
+		rec.text = p.cstring()
+		return rec, true
+	case '^', '*', '+', '=':
+		class, results, _ := strings.Cut(rest, ",")
+		rec.class = class
+		rec.results = map[string]interface{}{}
+		if results != "" {
+			p := &miParser{s: results}
+			p.results(rec.results, 0)
+		}
+		return rec, true
+	}
+	return rec, false
+}
+
+// miParser MI 结果的递归下降解析器
+type miParser struct {
+	s string
+	i int
+}
+
+// results 解析 key=value 列表直到 end（0 表示到行尾）
+func (p *miParser) results(into map[string]interface{}, end byte) {
+	for p.i < len(p.s) && p.s[p.i] != end {
+		key := p.key()
+		if p.i >= len(p.s) || p.s[p.i] != '=' {
+			return
+		}
+		p.i++
+		into[key] = p.value()
+		if p.i < len(p.s) && p.s[p.i] == ',' {
+			p.i++
+		}
+	}
+}
+
+// key 解析结果中的键
+func (p *miParser) key() string {
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] != '=' && p.s[p.i] != ',' {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// value 解析值: c-string、{tuple} 或 [list]
+func (p *miParser) value() interface{} {
+	if p.i >= len(p.s) {
+		return ""
+	}
+	switch p.s[p.i] {
+	case '"':
+		return p.cstring()
+	case '{':
+		p.i++
+		m := map[string]interface{}{}
+		p.results(m, '}')
+		p.i++
+		return m
+	case '[':
+		p.i++
+		list := []interface{}{}
+		for p.i < len(p.s) && p.s[p.i] != ']' {
+			if p.s[p.i] != '"' && p.s[p.i] != '{' && p.s[p.i] != '[' {
+				// 结果列表（key=value），只保留值
+				p.key()
+				p.i++
+			}
+			list = append(list, p.value())
+			if p.i < len(p.s) && p.s[p.i] == ',' {
+				p.i++
+			}
+		}
+		p.i++
+		return list
+	}
+	return ""
+}
+
+// cstring 解析 C 风格的带引号字符串
+func (p *miParser) cstring() string {
+	if p.i >= len(p.s) || p.s[p.i] != '"' {
+		return ""
+	}
+	start := p.i
+	p.i++
+	for p.i < len(p.s) && p.s[p.i] != '"' {
+		if p.s[p.i] == '\\' {
+			p.i++
+		}
+		p.i++
+	}
+	p.i++
+	if p.i > len(p.s) {
+		p.i = len(p.s)
+	}
+	raw := p.s[start:p.i]
+	if s, err := strconv.Unquote(raw); err == nil {
+		return s
+	}
+	return strings.Trim(raw, `"`)
+}
+
+// miString 取结果中的字符串
+func miString(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// miInt 取结果中的整数
+func miInt(m map[string]interface{}, key string) int {
+	n, _ := strconv.Atoi(miString(m, key))
+	return n
+}
+
+// miTuple 取结果中的元组
+func miTuple(m map[string]interface{}, key string) map[string]interface{} {
+	t, _ := m[key].(map[string]interface{})
+	return t
+}
+
+// miList 取结果中的列表
+func miList(m map[string]interface{}, key string) []interface{} {
+	l, _ := m[key].([]interface{})
+	return l
+}
+
+// miQuote 将字符串转为 MI 命令参数中的 C 字符串
+func miQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
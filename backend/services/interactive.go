@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cocode/backend/models"
+)
+
+// 交互器的文件参数（位于交互器运行目录中）
+const (
+	interactorInputFile  = "input.txt"
+	interactorOutputFile = "tout.txt"
+)
+
+// transcriptLimit 交互记录最多保留的字节数
+const transcriptLimit = 64 * 1024
+
+// ValidateInteractorConfig 校验交互器配置
+func ValidateInteractorConfig(cfg models.InteractorConfig) error {
+	if strings.TrimSpace(cfg.Code) == "" {
+		return fmt.Errorf("交互器代码不能为空")
+	}
+	_, err := GetLanguage(cfg.Language)
+	return err
+}
+
+// CompileAndInteract 编译程序和交互器，通过管道连接两者运行
+// 交互器以 testlib 方式调用: interactor input.txt tout.txt，其退出码决定判定；交互记录作为输出返回
+func CompileAndInteract(lang *Language, code string, input string, interactorCfg models.InteractorConfig, opts RunOptions) *models.CompileResult {
+	if err := ValidateInteractorConfig(interactorCfg); err != nil {
+		return &models.CompileResult{Verdict: models.VerdictFail, Message: "交互器错误: " + err.Error()}
+	}
+
+	interactorLang, _ := GetLanguage(interactorCfg.Language)
+	interactor, interactorResult := compileSource(opts.context(), interactorLang, interactorCfg.Code)
+	if interactor == nil {
+		if interactorResult.Verdict != models.VerdictCancelled {
+			interactorResult.Verdict = models.VerdictFail
+		}
+		interactorResult.Message = "交互器" + interactorResult.Message
+		return interactorResult
+	}
+	defer interactor.cleanup()
+
+	b, result := compileSource(opts.context(), lang, code)
+	if b == nil {
+		return result
+	}
+	defer b.cleanup()
+
+	if err := os.WriteFile(filepath.Join(interactor.dir, interactorInputFile), []byte(input), 0644); err != nil {
+		result.Message += fmt.Sprintf("\n写入交互器输入失败: %v", err)
+		return result
+	}
+
+	solOutcome, interactorOutcome, transcript, err := runInteraction(opts.context(), b, interactor)
+	if err != nil {
+		result.Message += fmt.Sprintf("\n创建管道失败: %v", err)
+		return result
+	}
+
+	result.Verdict, result.CheckMessage = interactionVerdict(solOutcome, interactorOutcome)
+	result.Signal = solOutcome.Signal
+	result.ExitCode = solOutcome.ExitCode
+	result.Output = transcript
+	result.Success = result.Verdict == models.VerdictAC
+	result.Stats = solOutcome.stats()
+	result.Message += "\n" + describeOutcome(solOutcome) + describeStats(result.Stats)
+	result.Message += "\n交互判定: " + result.Verdict
+	if result.CheckMessage != "" {
+		result.Message += " " + result.CheckMessage
+	}
+	return result
+}
+
+// runInteraction 并发运行程序和交互器:
+// 程序 stdout -> 交互器 stdin，交互器 stdout -> 程序 stdin，中间转发时记录交互内容
+func runInteraction(ctx context.Context, sol, interactor *build) (*runOutcome, *runOutcome, string, error) {
+	var files []*os.File
+	pipe := func() (*os.File, *os.File, error) {
+		r, w, err := os.Pipe()
+		if err == nil {
+			files = append(files, r, w)
+		}
+		return r, w, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	solOutR, solOutW, err := pipe()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	intInR, intInW, err := pipe()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	intOutR, intOutW, err := pipe()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	solInR, solInW, err := pipe()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	record := &transcript{}
+	var pumps sync.WaitGroup
+	pumps.Add(2)
+	go func() {
+		defer pumps.Done()
+		pumpInteraction(solOutR, intInW, record, "程序")
+	}()
+	go func() {
+		defer pumps.Done()
+		pumpInteraction(intOutR, solInW, record, "交互器")
+	}()
+
+	var solOutcome, interactorOutcome *runOutcome
+	var runs sync.WaitGroup
+	runs.Add(2)
+	go func() {
+		defer runs.Done()
+		req := sol.command(solInR)
+		req.Ctx = ctx
+		req.Stdout = solOutW
+		req.closeAfterStart = []io.Closer{solInR, solOutW}
+		solOutcome = runSandboxed(req)
+	}()
+	go func() {
+		defer runs.Done()
+		req := interactor.command(intInR, interactorInputFile, interactorOutputFile)
+		req.Ctx = ctx
+		req.Stdout = intOutW
+		req.closeAfterStart = []io.Closer{intInR, intOutW}
+		interactorOutcome = runSandboxed(req)
+	}()
+	runs.Wait()
+	pumps.Wait()
+
+	return solOutcome, interactorOutcome, record.String(), nil
+}
+
+// pumpInteraction 将 src 的数据转发到 dst 并记录；任一端关闭后关闭另一端，使对方收到 EOF 或 SIGPIPE
+func pumpInteraction(src, dst *os.File, record *transcript, from string) {
+	defer src.Close()
+	defer dst.Close()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			record.add(from, buf[:n])
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// interactionVerdict 综合程序和交互器的运行情况给出判定
+func interactionVerdict(sol, interactor *runOutcome) (string, string) {
+	comment := truncate(strings.TrimSpace(interactor.Stderr), 200)
+
+	if sol.Cancelled || interactor.Cancelled {
+		return models.VerdictCancelled, ""
+	}
+
+	// 程序资源超限优先
+	switch verdict := sol.Verdict(); verdict {
+	case models.VerdictTLE, models.VerdictMLE, models.VerdictOLE:
+		return verdict, ""
+	}
+
+	if interactor.Signal == "" && !interactor.TimedOut && interactor.StartErr == nil {
+		switch interactor.ExitCode {
+		case testlibWA:
+			return models.VerdictWA, comment
+		case testlibPE:
+			return models.VerdictWA, "格式错误: " + comment
+		}
+	}
+
+	// 交互器提前判错时程序可能因管道关闭收到 SIGPIPE，此时以交互器结果为准
+	if sol.Verdict() == models.VerdictRE {
+		return models.VerdictRE, ""
+	}
+
+	if interactor.Verdict() == models.VerdictOK {
+		return models.VerdictAC, comment
+	}
+	if interactor.ExitCode == testlibFail {
+		return models.VerdictFail, "交互器判定失败: " + comment
+	}
+	return models.VerdictFail, "交互器异常: " + describeOutcome(interactor)
+}
+
+// transcript 交互记录，按发送方分段
+type transcript struct {
+	mu   sync.Mutex
+	buf  strings.Builder
+	last string
+}
+
+// add 追加一段数据，发送方变化时另起一段
+func (t *transcript) add(from string, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.buf.Len() >= transcriptLimit {
+		return
+	}
+	if from != t.last {
+		if t.buf.Len() > 0 && !strings.HasSuffix(t.buf.String(), "\n") {
+			t.buf.WriteString("\n")
+		}
+		t.buf.WriteString("[" + from + "] ")
+		t.last = from
+	}
+	if remain := transcriptLimit - t.buf.Len(); len(data) > remain {
+		remain = max(remain, 0)
+		t.buf.Write(data[:validUTF8Prefix(data[:remain])])
+		t.buf.WriteString("\n...(已截断)")
+		return
+	}
+	t.buf.Write(data)
+}
+
+// String 返回完整的交互记录
+func (t *transcript) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.String()
+}
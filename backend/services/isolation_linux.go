@@ -0,0 +1,229 @@
+//go:build linux
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"cocode/backend/config"
+)
+
+// namespaceCloneFlags 命名空间隔离模式下创建的命名空间
+const namespaceCloneFlags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET |
+	syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+
+// defaultReadonlyMounts 默认只读挂载进沙箱的系统目录（运行时库和解释器）
+var defaultReadonlyMounts = []string{"/bin", "/lib", "/lib64", "/usr"}
+
+// 隔离模式下辅助进程使用的文件描述符
+const (
+	sandboxStatusFD = 3 // init 进程向服务端报告目标程序退出状态的管道
+	sandboxExeFD    = 3 // 第二阶段辅助进程中自身的程序文件，exec 目标程序时关闭
+)
+
+// sandboxStatus init 进程报告的目标程序退出状态
+type sandboxStatus struct {
+	ExitCode int `json:"exitCode"`
+	Signal   int `json:"signal,omitempty"` // 终止信号，0 表示正常退出
+}
+
+// sandboxDevices 沙箱内可用的设备文件
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"}
+
+// sandboxMountPoints 隔离模式下运行目录中被系统目录和设备占用的顶层名称
+func sandboxMountPoints() []string {
+	mounts := config.AppConfig.Compiler.ReadonlyMounts
+	if len(mounts) == 0 {
+		mounts = defaultReadonlyMounts
+	}
+	names := []string{"dev", "proc"}
+	for _, m := range mounts {
+		if name := strings.SplitN(strings.TrimPrefix(filepath.Clean(m), "/"), "/", 2)[0]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// applyNamespaceAttrs 为命令设置命名空间和 uid/gid 映射
+// 沙箱内的 root 映射为服务进程自身的用户，不具备宿主机上的任何额外权限
+func applyNamespaceAttrs(attr *syscall.SysProcAttr) {
+	attr.Cloneflags = namespaceCloneFlags
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// setupIsolatedRoot 在新的挂载命名空间中以 root 目录为根，只读挂载系统目录
+// 调用后当前进程的根目录和工作目录均为 root
+func setupIsolatedRoot(root string, mounts []string) error {
+	// 挂载传播设为私有，沙箱内的挂载不影响宿主
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("设置挂载传播失败: %v", err)
+	}
+	// pivot_root 要求新根目录本身是挂载点
+	if err := syscall.Mount(root, root, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("绑定运行目录失败: %v", err)
+	}
+
+	if len(mounts) == 0 {
+		mounts = defaultReadonlyMounts
+	}
+	for _, src := range mounts {
+		if err := bindReadonly(src, filepath.Join(root, src)); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "dev"), 0755); err != nil {
+		return err
+	}
+	for _, dev := range sandboxDevices {
+		dst := filepath.Join(root, dev)
+		if err := os.WriteFile(dst, nil, 0644); err != nil {
+			return err
+		}
+		if err := syscall.Mount(dev, dst, "", syscall.MS_BIND, ""); err != nil {
+			return fmt.Errorf("挂载 %s 失败: %v", dev, err)
+		}
+	}
+
+	// 新的 procfs 只包含沙箱内的进程（JVM 等运行时需要 /proc）
+	procDir := filepath.Join(root, "proc")
+	if err := os.MkdirAll(procDir, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", procDir, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("挂载 /proc 失败: %v", err)
+	}
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.MkdirAll(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("切换根目录失败: %v", err)
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Unmount("/.oldroot", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("卸载原根目录失败: %v", err)
+	}
+	os.Remove("/.oldroot")
+
+	hostname := []byte("sandbox")
+	return syscall.Sethostname(hostname)
+}
+
+// runSandboxInit 隔离模式下辅助进程是 PID 命名空间的 init 进程：完成隔离后再次执行自身启动目标程序
+// （由第二阶段设置资源限制和 seccomp 后 exec），回收进程直到目标程序结束，通过 sandboxStatusFD 报告其退出状态
+// 目标程序不能作为 init 进程运行：init 进程没有处理函数的信号（如 abort() 发出的 SIGABRT）都会被忽略
+func runSandboxInit(spec sandboxSpec, args []string) error {
+	syscall.CloseOnExec(sandboxStatusFD)
+	// pivot_root 之后宿主上的程序文件不可见，先打开自身
+	self, err := os.Open("/proc/self/exe")
+	if err != nil {
+		return fmt.Errorf("打开沙箱辅助程序失败: %v", err)
+	}
+	if err := setupIsolatedRoot(spec.Root, spec.Mounts); err != nil {
+		return err
+	}
+	if err := dropCapabilities(); err != nil {
+		return fmt.Errorf("丢弃能力失败: %v", err)
+	}
+
+	// 接管所有信号，避免 init 进程因终端信号等提前退出（exec 后目标程序恢复默认处理）
+	signal.Notify(make(chan os.Signal, 1))
+
+	child := sandboxSpec{Limits: spec.Limits, Isolated: true}
+	childJSON, err := json.Marshal(child)
+	if err != nil {
+		return err
+	}
+	argv := append([]string{"sandbox", sandboxHelperArg, string(childJSON)}, args...)
+	pid, err := syscall.ForkExec(fmt.Sprintf("/proc/self/fd/%d", sandboxExeFD), argv, &syscall.ProcAttr{
+		Env:   os.Environ(),
+		Files: []uintptr{0, 1, 2, self.Fd()},
+	})
+	if err != nil {
+		return fmt.Errorf("启动程序失败: %v", err)
+	}
+	self.Close()
+
+	for {
+		var ws syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("等待程序结束失败: %v", err)
+		}
+		if wpid != pid {
+			continue // 回收目标程序遗留的子进程
+		}
+
+		status := sandboxStatus{ExitCode: ws.ExitStatus()}
+		if ws.Signaled() {
+			status.Signal = int(ws.Signal())
+			status.ExitCode = 128 + status.Signal
+		}
+		data, _ := json.Marshal(status)
+		os.NewFile(sandboxStatusFD, "status").Write(data)
+		// init 进程退出时内核结束命名空间内的其余进程
+		os.Exit(status.ExitCode)
+	}
+}
+
+// bindReadonly 将宿主目录只读绑定到沙箱内，符号链接按原样复制
+func bindReadonly(src, dst string) error {
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		// 同一运行目录中多次运行（如逐个运行测试点）时链接已存在
+		if existing, err := os.Readlink(dst); err == nil && existing == target {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("挂载 %s 失败: %v", src, err)
+	}
+
+	// 用户命名空间内重新挂载时必须保留原挂载点上锁定的标志
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(src, &st); err != nil {
+		return err
+	}
+	locked := uintptr(st.Flags) & (syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOATIME |
+		syscall.MS_NODIRATIME | syscall.MS_RELATIME)
+	flags := syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY | syscall.MS_NOSUID | locked
+	if err := syscall.Mount("", dst, "", flags, ""); err != nil {
+		return fmt.Errorf("只读挂载 %s 失败: %v", src, err)
+	}
+	return nil
+}
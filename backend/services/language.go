@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 命令模板中的占位符，均相对于运行目录展开
+const (
+	placeholderDir = "{dir}" // 运行目录
+	placeholderSrc = "{src}" // 源文件
+	placeholderExe = "{exe}" // 编译产物
+)
+
+// executableName 编译产物在运行目录中的文件名
+const executableName = "main"
+
+// Language 编程语言工具链
+type Language struct {
+	Name               string            `json:"name"`
+	DisplayName        string            `json:"displayName"`
+	SourceFile         string            `json:"sourceFile"`
+	CompileCommand     []string          `json:"-"` // 为空表示无需编译（解释型语言）
+	DiagnosticsCommand []string          `json:"-"` // 后台语法检查使用的命令（输出 JSON/SARIF 诊断），可为空
+	RunCommand         []string          `json:"-"`
+	CompileTimeout     time.Duration     `json:"-"`
+	RunTimeout         time.Duration     `json:"-"`
+	MemoryLimit        int               `json:"-"` // 地址空间限制（MB），0 表示使用全局配置，负数表示不限制
+	Profile            *Profile          `json:"-"` // 使用的编译配置，可为空
+	Flags              []string          `json:"-"` // 本次编译附加的编译参数
+	RunEnv             []string          `json:"-"` // 运行时追加的环境变量
+	Files              []models.CodeFile `json:"-"` // 工作区中主文件以外的文件
+	Coverage           bool              `json:"-"` // 是否为覆盖率运行（运行后收集 gcov 数据）
+}
+
+// Extension 源文件扩展名
+func (l *Language) Extension() string {
+	return filepath.Ext(l.SourceFile)
+}
+
+// NeedsCompile 是否需要编译步骤
+func (l *Language) NeedsCompile() bool {
+	return len(l.CompileCommand) > 0
+}
+
+// Limits 该语言程序运行时的资源限制
+func (l *Language) Limits() SandboxLimits {
+	limits := limitsFromConfig()
+	if l.MemoryLimit > 0 {
+		limits.Memory = int64(l.MemoryLimit) * 1024 * 1024
+	} else if l.MemoryLimit < 0 {
+		// Sanitizer 等需要预留大量虚拟地址空间
+		limits.Memory = 0
+	}
+	return limits
+}
+
+// ProfileName 使用的编译配置名称，未使用时为空
+func (l *Language) ProfileName() string {
+	if l.Profile == nil {
+		return ""
+	}
+	return l.Profile.Name
+}
+
+// Label 显示名称，附带编译配置和附加的编译参数
+func (l *Language) Label() string {
+	label := l.DisplayName
+	if l.Profile != nil {
+		label += " / " + l.Profile.DisplayName
+	}
+	if len(l.Flags) > 0 {
+		label += " " + strings.Join(l.Flags, " ")
+	}
+	return label
+}
+
+// newLanguage 根据配置构造语言，未配置的超时沿用 [compiler] 中的全局值
+func newLanguage(cfg config.LanguageConfig) *Language {
+	lang := &Language{
+		Name:               cfg.Name,
+		DisplayName:        cfg.DisplayName,
+		SourceFile:         cfg.SourceFile,
+		CompileCommand:     cfg.CompileCommand,
+		DiagnosticsCommand: cfg.DiagnosticsCommand,
+		RunCommand:         cfg.RunCommand,
+		MemoryLimit:        cfg.MemoryLimit,
+		CompileTimeout:     time.Duration(config.AppConfig.Compiler.CompileTimeout) * time.Second,
+		RunTimeout:         time.Duration(config.AppConfig.Compiler.RunTimeout) * time.Second,
+	}
+	if lang.DisplayName == "" {
+		lang.DisplayName = lang.Name
+	}
+	if cfg.CompileTimeout > 0 {
+		lang.CompileTimeout = time.Duration(cfg.CompileTimeout) * time.Second
+	}
+	if cfg.RunTimeout > 0 {
+		lang.RunTimeout = time.Duration(cfg.RunTimeout) * time.Second
+	}
+	return lang
+}
+
+// defaultLanguageConfig 未配置 [[languages]] 时，由 [compiler] 生成 C++ 工具链
+func defaultLanguageConfig() config.LanguageConfig {
+	cfg := config.AppConfig.Compiler
+	compile := append([]string{cfg.Compiler}, cfg.CompileFlags...)
+	compile = append(compile, placeholderSrc, "-o", placeholderExe)
+	diagnostics := append([]string{cfg.Compiler}, cfg.CompileFlags...)
+	diagnostics = append(diagnostics, "-fsyntax-only", "-fdiagnostics-format=json", placeholderSrc)
+	return config.LanguageConfig{
+		Name:               "cpp",
+		DisplayName:        "C++",
+		SourceFile:         "main.cpp",
+		CompileCommand:     compile,
+		DiagnosticsCommand: diagnostics,
+		RunCommand:         []string{placeholderExe},
+	}
+}
+
+// ListLanguages 获取所有可用语言
+func ListLanguages() []*Language {
+	configs := config.AppConfig.Languages
+	if len(configs) == 0 {
+		configs = []config.LanguageConfig{defaultLanguageConfig()}
+	}
+	languages := make([]*Language, 0, len(configs))
+	for _, cfg := range configs {
+		languages = append(languages, newLanguage(cfg))
+	}
+	return languages
+}
+
+// DefaultLanguage 默认语言（配置中的第一个）
+func DefaultLanguage() *Language {
+	return ListLanguages()[0]
+}
+
+// GetLanguage 根据名称获取语言，名称为空时返回默认语言
+func GetLanguage(name string) (*Language, error) {
+	if name == "" {
+		return DefaultLanguage(), nil
+	}
+	for _, lang := range ListLanguages() {
+		if lang.Name == name {
+			return lang, nil
+		}
+	}
+	return nil, fmt.Errorf("不支持的语言: %s", name)
+}
+
+// expandCommand 展开命令模板中的占位符
+func (l *Language) expandCommand(command []string) []string {
+	replacer := strings.NewReplacer(
+		placeholderDir, ".",
+		placeholderSrc, l.SourceFile,
+		placeholderExe, "./"+executableName,
+	)
+	expanded := make([]string, len(command))
+	for i, arg := range command {
+		expanded[i] = replacer.Replace(arg)
+	}
+	return expanded
+}
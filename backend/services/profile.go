@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+)
+
+// maxExtraFlags 单次编译最多附加的编译参数数量
+const maxExtraFlags = 32
+
+// Profile 命名编译配置：以指定的编译器和参数编译某种语言
+type Profile struct {
+	Name        string   `json:"name"`
+	DisplayName string   `json:"displayName"`
+	Language    string   `json:"language"`
+	Compiler    string   `json:"compiler"`
+	Flags       []string `json:"flags"`
+	Available   bool     `json:"available"` // 服务器上是否安装了该编译器
+	cfg         config.ProfileConfig
+}
+
+// newProfile 根据配置构造编译配置
+func newProfile(cfg config.ProfileConfig) *Profile {
+	p := &Profile{
+		Name:        cfg.Name,
+		DisplayName: cfg.DisplayName,
+		Language:    cfg.Language,
+		Compiler:    cfg.Compiler,
+		Flags:       cfg.Flags,
+		cfg:         cfg,
+	}
+	if p.DisplayName == "" {
+		p.DisplayName = p.Name
+	}
+	if p.Flags == nil {
+		p.Flags = []string{}
+	}
+	_, err := exec.LookPath(cfg.Compiler)
+	p.Available = err == nil
+	return p
+}
+
+// ListProfiles 获取所有编译配置
+func ListProfiles() []*Profile {
+	profiles := make([]*Profile, 0, len(config.AppConfig.Profiles))
+	for _, cfg := range config.AppConfig.Profiles {
+		profiles = append(profiles, newProfile(cfg))
+	}
+	return profiles
+}
+
+// GetProfile 根据名称获取编译配置
+func GetProfile(name string) (*Profile, error) {
+	for _, cfg := range config.AppConfig.Profiles {
+		if cfg.Name == name {
+			return newProfile(cfg), nil
+		}
+	}
+	return nil, fmt.Errorf("未知的编译配置: %s", name)
+}
+
+// apply 以编译配置替换语言的编译命令和资源限制
+func (p *Profile) apply(lang *Language) *Language {
+	l := *lang
+	l.Profile = p
+
+	compile := append([]string{p.cfg.Compiler}, p.cfg.Flags...)
+	compile = append(compile, placeholderSrc, "-o", placeholderExe)
+	l.CompileCommand = append(compile, p.cfg.LinkFlags...)
+
+	// GCC 系编译器沿用 JSON 诊断，其他编译器解析文本输出
+	l.DiagnosticsCommand = nil
+	if isGCC(p.cfg.Compiler) && len(lang.DiagnosticsCommand) > 0 {
+		diagnostics := append([]string{p.cfg.Compiler}, p.cfg.Flags...)
+		l.DiagnosticsCommand = append(diagnostics, "-fsyntax-only", "-fdiagnostics-format=json", placeholderSrc)
+	}
+
+	if p.cfg.CompileTimeout > 0 {
+		l.CompileTimeout = time.Duration(p.cfg.CompileTimeout) * time.Second
+	}
+	if p.cfg.RunTimeout > 0 {
+		l.RunTimeout = time.Duration(p.cfg.RunTimeout) * time.Second
+	}
+	if p.cfg.MemoryLimit != 0 {
+		l.MemoryLimit = p.cfg.MemoryLimit
+	}
+	return &l
+}
+
+// isGCC 是否为 GCC 编译器（g++、gcc、g++-12 等）
+func isGCC(compiler string) bool {
+	name := filepath.Base(compiler)
+	return strings.HasPrefix(name, "g++") || strings.HasPrefix(name, "gcc")
+}
+
+// ResolveLanguage 获取本次编译使用的工具链：在语言的基础上应用编译配置和附加的编译参数
+// 附加参数必须符合 [compiler] 中的 allowed_flags
+func ResolveLanguage(name, profile string, flags []string) (*Language, error) {
+	lang, err := GetLanguage(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile != "" {
+		p, err := GetProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+		if p.Language != lang.Name {
+			return nil, fmt.Errorf("编译配置 %s 不适用于 %s", p.DisplayName, lang.DisplayName)
+		}
+		if !p.Available {
+			return nil, fmt.Errorf("编译配置 %s 不可用: 服务器未安装 %s", p.DisplayName, p.Compiler)
+		}
+		lang = p.apply(lang)
+	}
+
+	if len(flags) == 0 {
+		return lang, nil
+	}
+	if err := checkFlags(flags); err != nil {
+		return nil, err
+	}
+	return lang.withFlags(flags)
+}
+
+// checkFlags 检查附加的编译参数是否都在白名单中
+func checkFlags(flags []string) error {
+	allowed := config.AppConfig.Compiler.AllowedFlags
+	if len(allowed) == 0 {
+		return fmt.Errorf("服务器不允许自定义编译参数")
+	}
+	if len(flags) > maxExtraFlags {
+		return fmt.Errorf("编译参数过多，最多 %d 个", maxExtraFlags)
+	}
+	for _, flag := range flags {
+		if !flagAllowed(flag, allowed) {
+			return fmt.Errorf("不允许的编译参数: %s", flag)
+		}
+	}
+	return nil
+}
+
+// flagAllowed 参数是否匹配白名单中的某一项（支持 * 通配符）
+func flagAllowed(flag string, allowed []string) bool {
+	if !strings.HasPrefix(flag, "-") {
+		return false
+	}
+	for _, pattern := range allowed {
+		if ok, _ := path.Match(pattern, flag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// withFlags 在编译命令和诊断命令的源文件参数之前插入附加参数
+func (l *Language) withFlags(flags []string) (*Language, error) {
+	compile, ok := insertBeforeSource(l.CompileCommand, flags)
+	if !ok {
+		return nil, fmt.Errorf("%s 不支持自定义编译参数", l.DisplayName)
+	}
+	lang := *l
+	lang.CompileCommand = compile
+	lang.DiagnosticsCommand, _ = insertBeforeSource(l.DiagnosticsCommand, flags)
+	lang.Flags = append(append([]string{}, l.Flags...), flags...)
+	for _, flag := range flags {
+		// AddressSanitizer 等需要预留大量虚拟地址空间，在地址空间限制下无法启动
+		if strings.HasPrefix(flag, "-fsanitize=") {
+			lang.MemoryLimit = -1
+			break
+		}
+	}
+	return &lang, nil
+}
+
+// insertBeforeSource 返回在 {src} 参数之前插入 flags 的新命令，命令中没有 {src} 时返回 false
+func insertBeforeSource(command, flags []string) ([]string, bool) {
+	for i, arg := range command {
+		if arg == placeholderSrc {
+			result := make([]string, 0, len(command)+len(flags))
+			result = append(result, command[:i]...)
+			result = append(result, flags...)
+			return append(result, command[i:]...), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package services
+
+import (
+	"fmt"
+	"os"
+)
+
+// openPTY 非 Linux 平台不支持伪终端
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, fmt.Errorf("当前平台不支持终端运行")
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cocode/backend/config"
+)
+
+// JudgeQueue 评测队列，限制同时进行的编译运行数量，并按用户限流和统计 CPU 配额
+type JudgeQueue struct {
+	mu      sync.Mutex
+	running int
+	waiting []*JudgeTicket
+
+	active  map[string]int           // 用户进行中（含排队）的运行数
+	recent  map[string][]time.Time   // 用户最近一分钟发起运行的时间
+	cpuUsed map[string]time.Duration // 用户当天已用的 CPU 时间
+	cpuDay  string                   // cpuUsed 对应的日期
+}
+
+// JudgeTicket 评测队列中的一次运行
+type JudgeTicket struct {
+	queue    *JudgeQueue
+	username string
+	ready    chan struct{}      // 轮到运行时关闭
+	onUpdate func(position int) // 排队位置变化时的回调（从 1 开始）
+	started  bool
+	released bool // 已提前释放运行名额
+	done     bool
+	cpu      atomic.Int64 // 本次运行累计的 CPU 时间（纳秒）
+}
+
+var (
+	judgeQueue     *JudgeQueue
+	judgeQueueOnce sync.Once
+)
+
+// GetJudgeQueue 获取全局评测队列
+func GetJudgeQueue() *JudgeQueue {
+	judgeQueueOnce.Do(func() {
+		judgeQueue = &JudgeQueue{
+			active:  make(map[string]int),
+			recent:  make(map[string][]time.Time),
+			cpuUsed: make(map[string]time.Duration),
+		}
+	})
+	return judgeQueue
+}
+
+// workers 同时运行的数量，未配置时为 CPU 核数
+func (q *JudgeQueue) workers() int {
+	if n := config.AppConfig.Queue.Workers; n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+// Enter 检查用户限额后加入队列；被拒绝时返回说明原因的错误
+// 返回的 ticket 需调用 Done 释放
+func (q *JudgeQueue) Enter(username string) (*JudgeTicket, error) {
+	cfg := config.AppConfig.Queue
+	now := time.Now()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if day := now.Format("2006-01-02"); day != q.cpuDay {
+		q.cpuDay = day
+		q.cpuUsed = make(map[string]time.Duration)
+	}
+	if cfg.DailyCPUSeconds > 0 && q.cpuUsed[username] >= time.Duration(cfg.DailyCPUSeconds)*time.Second {
+		return nil, fmt.Errorf("今日 CPU 时间配额（%d 秒）已用完", cfg.DailyCPUSeconds)
+	}
+	if cfg.MaxConcurrent > 0 && q.active[username] >= cfg.MaxConcurrent {
+		return nil, fmt.Errorf("你已有 %d 个运行在进行中，请等待完成后再试", q.active[username])
+	}
+
+	recent := q.recent[username][:0]
+	for _, t := range q.recent[username] {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	q.recent[username] = recent
+	if cfg.MaxPerMinute > 0 && len(recent) >= cfg.MaxPerMinute {
+		return nil, fmt.Errorf("运行过于频繁，每分钟最多 %d 次", cfg.MaxPerMinute)
+	}
+
+	if cfg.MaxPending > 0 && len(q.waiting) >= cfg.MaxPending && q.running >= q.workers() {
+		return nil, fmt.Errorf("评测队列已满（%d 个排队），请稍后再试", len(q.waiting))
+	}
+
+	q.recent[username] = append(recent, now)
+	q.active[username]++
+
+	t := &JudgeTicket{queue: q, username: username, ready: make(chan struct{})}
+	if q.running < q.workers() && len(q.waiting) == 0 {
+		q.running++
+		t.started = true
+		close(t.ready)
+	} else {
+		q.waiting = append(q.waiting, t)
+	}
+	return t, nil
+}
+
+// Position 当前排队位置（从 1 开始），已开始运行时为 0
+func (t *JudgeTicket) Position() int {
+	q := t.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, w := range q.waiting {
+		if w == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Wait 等待轮到运行，排队位置变化时调用 onUpdate；ctx 取消时放弃排队并返回错误
+func (t *JudgeTicket) Wait(ctx context.Context, onUpdate func(position int)) error {
+	t.queue.mu.Lock()
+	t.onUpdate = onUpdate
+	t.queue.mu.Unlock()
+
+	if pos := t.Position(); pos > 0 && onUpdate != nil {
+		onUpdate(pos)
+	}
+
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Context 返回记录 CPU 时间的上下文，在其下进行的编译运行都计入本次运行的用量
+func (t *JudgeTicket) Context(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cpuMeterKey{}, t)
+}
+
+// Done 释放运行名额并计入 CPU 用量，可重复调用
+func (t *JudgeTicket) Done() {
+	q := t.queue
+	q.mu.Lock()
+	if t.done {
+		q.mu.Unlock()
+		return
+	}
+	t.done = true
+
+	q.active[t.username]--
+	if q.active[t.username] <= 0 {
+		delete(q.active, t.username)
+	}
+	q.cpuUsed[t.username] += time.Duration(t.cpu.Load())
+
+	if t.started {
+		if !t.released {
+			q.running--
+		}
+	} else {
+		for i, w := range q.waiting {
+			if w == t {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				break
+			}
+		}
+	}
+
+	notify := q.dispatchLocked()
+	q.mu.Unlock()
+
+	for _, n := range notify {
+		n()
+	}
+}
+
+// Release 提前释放运行名额，用于长时间等待用户操作的调试会话
+// 用户的并发计数和 CPU 用量仍在 Done 时结算
+func (t *JudgeTicket) Release() {
+	q := t.queue
+	q.mu.Lock()
+	if t.done || !t.started || t.released {
+		q.mu.Unlock()
+		return
+	}
+	t.released = true
+	q.running--
+	notify := q.dispatchLocked()
+	q.mu.Unlock()
+
+	for _, n := range notify {
+		n()
+	}
+}
+
+// dispatchLocked 依次启动排队的运行，返回通知排队者新位置的回调（需持有锁，回调在锁外调用）
+func (q *JudgeQueue) dispatchLocked() []func() {
+	for q.running < q.workers() && len(q.waiting) > 0 {
+		next := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		next.started = true
+		q.running++
+		close(next.ready)
+	}
+	return q.positionsLocked()
+}
+
+// positionsLocked 生成通知排队者新位置的回调（需持有锁，回调在锁外调用）
+func (q *JudgeQueue) positionsLocked() []func() {
+	notify := make([]func(), 0, len(q.waiting))
+	for i, w := range q.waiting {
+		if w.onUpdate != nil {
+			update, pos := w.onUpdate, i+1
+			notify = append(notify, func() { update(pos) })
+		}
+	}
+	return notify
+}
+
+// CPUUsed 用户当天已用的 CPU 时间
+func (q *JudgeQueue) CPUUsed(username string) time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cpuDay != time.Now().Format("2006-01-02") {
+		return 0
+	}
+	return q.cpuUsed[username]
+}
+
+// Stats 返回正在运行和排队的数量
+func (q *JudgeQueue) Stats() (running, waiting int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running, len(q.waiting)
+}
+
+// cpuMeterKey 上下文中 CPU 用量记录的键
+type cpuMeterKey struct{}
+
+// chargeCPU 将 CPU 时间计入上下文所属的排队运行
+func chargeCPU(ctx context.Context, d time.Duration) {
+	if ctx == nil {
+		return
+	}
+	if t, ok := ctx.Value(cpuMeterKey{}).(*JudgeTicket); ok {
+		t.cpu.Add(int64(d))
+	}
+}
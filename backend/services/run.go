@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 运行类型
+const (
+	RunKindCompile   = "compile"   // 编译运行
+	RunKindTests     = "run_tests" // 批量运行测试用例
+	RunKindStress    = "stress"    // 对拍
+	RunKindDebug     = "debug"     // 调试会话
+	RunKindAnalyze   = "analyze"   // 静态分析
+	RunKindAsm       = "asm"       // 查看汇编
+	RunKindBenchmark = "benchmark" // 基准测试
+	RunKindFuzz      = "fuzz"      // 模糊测试
+)
+
+// RunHandle 一次进行中的编译运行，可被房间内的成员取消
+type RunHandle struct {
+	ID      string
+	Kind    string
+	Owner   *Client
+	Started time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	cancelledBy string
+}
+
+// Context 返回本次运行的上下文，运行被取消或结束后失效
+func (r *RunHandle) Context() context.Context {
+	return r.ctx
+}
+
+// Cancel 取消运行，by 为取消者的显示名称；已取消过时返回 false
+func (r *RunHandle) Cancel(by string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelledBy != "" {
+		return false
+	}
+	r.cancelledBy = by
+	r.cancel()
+	return true
+}
+
+// CancelledBy 返回取消者的显示名称，未被取消时为空
+func (r *RunHandle) CancelledBy() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancelledBy
+}
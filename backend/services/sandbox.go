@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// sandboxHelperArg 沙箱辅助进程的命令行标记
+// 运行用户程序时，服务端会以该参数重新执行自身，由辅助进程设置资源限制后再 exec 目标程序
+const sandboxHelperArg = "__cocode_sandbox__"
+
+// SandboxLimits 程序运行的资源限制（0 表示不限制）
+type SandboxLimits struct {
+	CPUTime   int   `json:"cpuTime"`   // CPU 时间（秒）
+	Memory    int64 `json:"memory"`    // 地址空间（字节）
+	Processes int   `json:"processes"` // 最大进程数
+	OpenFiles int   `json:"openFiles"` // 最大打开文件数
+	FileSize  int64 `json:"fileSize"`  // 单个文件最大写入量（字节）
+}
+
+// limitsFromConfig 根据配置生成资源限制
+func limitsFromConfig() SandboxLimits {
+	cfg := config.AppConfig.Compiler
+	return SandboxLimits{
+		CPUTime:   cfg.CPUTimeLimit,
+		Memory:    int64(cfg.MemoryLimit) * 1024 * 1024,
+		Processes: cfg.MaxProcesses,
+		OpenFiles: cfg.MaxOpenFiles,
+		FileSize:  outputLimitBytes(),
+	}
+}
+
+// outputLimitBytes 输出大小上限（字节）
+func outputLimitBytes() int64 {
+	return int64(config.AppConfig.Compiler.OutputLimit) * 1024
+}
+
+// sandboxEnv 用户程序的运行环境变量（不继承服务进程的环境）
+var sandboxEnv = []string{
+	"PATH=/usr/local/bin:/usr/bin:/bin",
+	"LANG=C.UTF-8",
+	"HOME=/tmp",
+}
+
+// IsSandboxHelper 判断当前进程是否为沙箱辅助进程
+func IsSandboxHelper() bool {
+	return len(os.Args) > 1 && os.Args[1] == sandboxHelperArg
+}
+
+// runRequest 沙箱运行请求
+type runRequest struct {
+	Path    string        // 可执行文件路径
+	Args    []string      // 命令行参数
+	Dir     string        // 工作目录
+	Stdin   io.Reader     // 标准输入
+	Stdout  io.Writer     // 标准输出，为空时缓存到结果中
+	Timeout time.Duration // 墙钟超时
+	Limits  SandboxLimits // 资源限制
+	Env     []string      // 追加的环境变量
+
+	OnOutput OutputFunc      // 运行中输出的回调（流式推送），可为空
+	Ctx      context.Context // 取消运行的上下文，可为空
+	Terminal *os.File        // 伪终端从设备，设置后作为程序的标准输入输出
+
+	closeAfterStart []io.Closer // 子进程启动后在父进程中关闭（如管道的子进程端）
+}
+
+// runOutcome 沙箱运行结果
+type runOutcome struct {
+	Stdout         string
+	Stderr         string
+	ExitCode       int
+	Signal         string // 终止信号名称，如 SIGSEGV
+	TimedOut       bool   // 墙钟超时或 CPU 时间超限
+	MemoryExceeded bool   // 超出内存限制
+	OutputExceeded bool   // 超出输出限制
+	StartErr       error  // 启动失败
+	Cancelled      bool   // 被用户取消
+
+	CPUTime  time.Duration // CPU 时间（用户态 + 内核态）
+	UserTime time.Duration // 用户态 CPU 时间
+	SysTime  time.Duration // 内核态 CPU 时间
+	WallTime time.Duration // 墙钟时间
+	MaxRSS   int64         // 峰值常驻内存（KB），仅 Linux 可用
+}
+
+// Verdict 根据运行情况给出判定
+func (o *runOutcome) Verdict() string {
+	switch {
+	case o.Cancelled:
+		return models.VerdictCancelled
+	case o.StartErr != nil:
+		return models.VerdictRE
+	case o.OutputExceeded:
+		return models.VerdictOLE
+	case o.TimedOut:
+		return models.VerdictTLE
+	case o.MemoryExceeded:
+		return models.VerdictMLE
+	case o.Signal != "" || o.ExitCode != 0:
+		return models.VerdictRE
+	}
+	return models.VerdictOK
+}
+
+// verdictText 判定的中文说明
+var verdictText = map[string]string{
+	models.VerdictOK:  "运行成功",
+	models.VerdictCE:  "编译错误",
+	models.VerdictTLE: "超出时间限制",
+	models.VerdictMLE: "超出内存限制",
+	models.VerdictRE:  "运行时错误",
+	models.VerdictOLE: "输出超出限制",
+
+	models.VerdictCancelled: "已取消",
+}
+
+// describeOutcome 生成运行结果的日志说明
+func describeOutcome(o *runOutcome) string {
+	verdict := o.Verdict()
+	msg := "运行结果: " + verdict + " " + verdictText[verdict]
+	if verdict != models.VerdictRE {
+		return msg
+	}
+	switch {
+	case o.StartErr != nil:
+		msg += "\n启动失败: " + o.StartErr.Error()
+	case o.Signal != "":
+		msg += " (信号 " + o.Signal + ")"
+	default:
+		msg += " (退出码 " + strconv.Itoa(o.ExitCode) + ")"
+	}
+	if o.Stderr != "" {
+		msg += "\n" + o.Stderr
+	}
+	return msg
+}
+
+// stats 生成运行统计，程序未能启动时返回 nil
+func (o *runOutcome) stats() *models.RunStats {
+	if o.StartErr != nil || (o.Cancelled && o.WallTime == 0) {
+		return nil
+	}
+	return &models.RunStats{
+		UserTime: o.UserTime.Milliseconds(),
+		SysTime:  o.SysTime.Milliseconds(),
+		WallTime: o.WallTime.Milliseconds(),
+		MaxRSS:   o.MaxRSS,
+		ExitCode: o.ExitCode,
+		Signal:   o.Signal,
+	}
+}
+
+// describeStats 生成运行统计的日志说明
+func describeStats(s *models.RunStats) string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("\nCPU 时间: %d ms (用户 %d ms, 系统 %d ms)，墙钟时间: %d ms，峰值内存: %d KB",
+		s.UserTime+s.SysTime, s.UserTime, s.SysTime, s.WallTime, s.MaxRSS)
+}
+
+// runSandboxed 在资源限制下运行程序
+func runSandboxed(req runRequest) *runOutcome {
+	outcome := &runOutcome{}
+	// 启动失败时也要关闭，否则管道另一端无法结束（重复关闭无副作用）
+	defer closeAll(req.closeAfterStart)
+
+	parent := req.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, req.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, req.Path, req.Args...)
+	cmd.Dir = req.Dir
+	cmd.Stdin = req.Stdin
+	cmd.Env = append(sandboxEnv[:len(sandboxEnv):len(sandboxEnv)], req.Env...)
+	cmd.WaitDelay = time.Second
+
+	limit := outputLimitBytes()
+	stdout := &limitedBuffer{limit: limit, onExceed: cancel}
+	stderr := &limitedBuffer{limit: limit, onExceed: cancel}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if req.OnOutput != nil {
+		streamer := newOutputStreamer(req.OnOutput)
+		defer streamer.Close()
+		cmd.Stdout = io.MultiWriter(stdout, streamer.Writer(StreamStdout))
+		cmd.Stderr = io.MultiWriter(stderr, streamer.Writer(StreamStderr))
+	}
+	if req.Stdout != nil {
+		cmd.Stdout = req.Stdout
+	}
+	if req.Terminal != nil {
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = req.Terminal, req.Terminal, req.Terminal
+	}
+
+	if parent.Err() != nil {
+		outcome.Cancelled = true
+		return outcome
+	}
+
+	sb, err := prepareSandbox(cmd, req)
+	if err != nil {
+		outcome.StartErr = err
+		return outcome
+	}
+	defer sb.cleanup()
+
+	start := time.Now()
+	err = cmd.Start()
+	closeAll(req.closeAfterStart)
+	if err == nil {
+		err = cmd.Wait()
+	}
+	outcome.WallTime = time.Since(start)
+	outcome.Stdout = stdout.String()
+	outcome.Stderr = stderr.String()
+	outcome.OutputExceeded = stdout.Exceeded() || stderr.Exceeded()
+
+	if err != nil && cmd.ProcessState == nil {
+		outcome.StartErr = err
+		return outcome
+	}
+
+	outcome.UserTime = cmd.ProcessState.UserTime()
+	outcome.SysTime = cmd.ProcessState.SystemTime()
+	outcome.CPUTime = outcome.UserTime + outcome.SysTime
+	chargeCPU(req.Ctx, outcome.CPUTime)
+	sb.inspect(cmd.ProcessState, outcome)
+	if parent.Err() != nil {
+		outcome.Cancelled = true
+	} else if ctx.Err() == context.DeadlineExceeded {
+		outcome.TimedOut = true
+	}
+	if !outcome.MemoryExceeded && req.Limits.Memory > 0 && isAllocFailure(outcome.Stderr) {
+		outcome.MemoryExceeded = true
+	}
+	return outcome
+}
+
+// closeAll 关闭所有对象
+func closeAll(closers []io.Closer) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+// isAllocFailure 判断标准错误中是否为内存分配失败
+func isAllocFailure(stderr string) bool {
+	return strings.Contains(stderr, "std::bad_alloc") ||
+		strings.Contains(stderr, "Cannot allocate memory") ||
+		strings.Contains(stderr, "out of memory")
+}
+
+// limitedBuffer 带上限的输出缓冲区，超出上限时丢弃多余内容并触发回调
+type limitedBuffer struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	limit    int64 // 0 表示不限制
+	exceeded bool
+	onExceed func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit > 0 && int64(b.buf.Len()+len(p)) > b.limit {
+		remain := b.limit - int64(b.buf.Len())
+		if remain > 0 {
+			b.buf.Write(p[:remain])
+		}
+		if !b.exceeded {
+			b.exceeded = true
+			if b.onExceed != nil {
+				b.onExceed()
+			}
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String 返回已缓存的内容
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Exceeded 是否超出上限
+func (b *limitedBuffer) Exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exceeded
+}
@@ -0,0 +1,366 @@
+//go:build linux
+
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"cocode/backend/config"
+)
+
+// rlimitNproc RLIMIT_NPROC（syscall 包未导出，x86/arm 上为 6）
+const rlimitNproc = 6
+
+// sandbox 单次运行占用的沙箱资源
+type sandbox struct {
+	limits    SandboxLimits
+	cgroupDir string   // cgroup v2 叶子节点目录，为空表示未启用
+	cgroupFD  *os.File // 叶子节点目录句柄，用于 clone 时直接加入 cgroup
+	status    *os.File // 隔离模式下读取目标程序退出状态的管道
+	statusW   *os.File // 管道的写入端（辅助进程的 sandboxStatusFD）
+}
+
+// sandboxSpec 传递给沙箱辅助进程的参数
+type sandboxSpec struct {
+	Limits   SandboxLimits `json:"limits"`
+	Root     string        `json:"root,omitempty"`     // 隔离模式下作为根目录的运行目录，由 init 进程使用
+	Mounts   []string      `json:"mounts,omitempty"`   // 只读挂载的系统目录
+	Isolated bool          `json:"isolated,omitempty"` // 由隔离模式的 init 进程启动，exec 前安装 seccomp 过滤器
+}
+
+// prepareSandbox 将命令改写为通过沙箱辅助进程启动，并按隔离后端设置命名空间和 cgroup
+func prepareSandbox(cmd *exec.Cmd, req runRequest) (*sandbox, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("定位沙箱辅助程序失败: %v", err)
+	}
+	target := cmd.Path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(req.Dir, target)
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return nil, fmt.Errorf("解析程序路径失败: %v", err)
+	}
+
+	killGroupOnCancel(cmd)
+	if req.Terminal != nil {
+		// 终端运行时新建会话并以伪终端为控制终端，会话首进程同时是进程组组长
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	}
+
+	spec := sandboxSpec{Limits: req.Limits}
+	if isolationBackend() == IsolationNamespace {
+		if req.Dir == "" {
+			return nil, fmt.Errorf("隔离模式需要指定运行目录")
+		}
+		root, err := filepath.Abs(req.Dir)
+		if err != nil {
+			return nil, err
+		}
+		// 运行目录中的程序换算为沙箱内路径，其他程序（如解释器）需位于只读挂载的系统目录中
+		if rel, err := filepath.Rel(root, target); err == nil && !strings.HasPrefix(rel, "..") {
+			target = "/" + filepath.ToSlash(rel)
+		}
+		spec.Root = root
+		spec.Mounts = config.AppConfig.Compiler.ReadonlyMounts
+		applyNamespaceAttrs(cmd.SysProcAttr)
+	}
+
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Args = append([]string{self, sandboxHelperArg, string(specJSON), target}, cmd.Args[1:]...)
+	cmd.Path = self
+
+	sb := &sandbox{limits: req.Limits}
+	if spec.Root != "" {
+		if sb.status, sb.statusW, err = os.Pipe(); err != nil {
+			return nil, err
+		}
+		cmd.ExtraFiles = []*os.File{sb.statusW}
+	}
+	if root := config.AppConfig.Compiler.CgroupPath; root != "" {
+		if err := sb.createCgroup(root); err != nil {
+			sb.cleanup()
+			return nil, err
+		}
+		cmd.SysProcAttr.UseCgroupFD = true
+		cmd.SysProcAttr.CgroupFD = int(sb.cgroupFD.Fd())
+	}
+	return sb, nil
+}
+
+// killGroupOnCancel 使命令在独立进程组中运行，超时或取消时连同子进程一起结束
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// createCgroup 在 cgroup v2 下创建本次运行的叶子节点
+func (sb *sandbox) createCgroup(root string) error {
+	dir := filepath.Join(root, fmt.Sprintf("run_%d", time.Now().UnixNano()))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return fmt.Errorf("创建 cgroup 失败: %v", err)
+	}
+	sb.cgroupDir = dir
+
+	settings := map[string]string{}
+	if sb.limits.Memory > 0 {
+		settings["memory.max"] = strconv.FormatInt(sb.limits.Memory, 10)
+		settings["memory.swap.max"] = "0"
+	}
+	if sb.limits.Processes > 0 {
+		settings["pids.max"] = strconv.Itoa(sb.limits.Processes)
+	}
+	for name, value := range settings {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+			sb.cleanup()
+			return fmt.Errorf("设置 cgroup %s 失败: %v", name, err)
+		}
+	}
+
+	fd, err := os.Open(dir)
+	if err != nil {
+		sb.cleanup()
+		return fmt.Errorf("打开 cgroup 失败: %v", err)
+	}
+	sb.cgroupFD = fd
+	return nil
+}
+
+// inspect 根据进程退出状态和资源使用情况填充运行结果
+func (sb *sandbox) inspect(state *os.ProcessState, o *runOutcome) {
+	if status, ok := sb.readStatus(); ok {
+		// 隔离模式下以 init 进程报告的目标程序状态为准
+		if status.Signal != 0 {
+			sb.inspectSignal(syscall.Signal(status.Signal), o)
+		} else {
+			o.ExitCode = status.ExitCode
+		}
+	} else if ws, ok := state.Sys().(syscall.WaitStatus); ok {
+		if ws.Signaled() {
+			sb.inspectSignal(ws.Signal(), o)
+		} else {
+			o.ExitCode = ws.ExitStatus()
+		}
+	}
+
+	// 启用 cgroup 时以叶子节点的 memory.peak 为准，否则取 rusage，其中包含 exec 前沙箱辅助进程的占用（约数 MB），小程序的结果偏大
+	if peak, ok := cgroupMemoryPeak(sb.cgroupDir); ok {
+		o.MaxRSS = peak / 1024
+	} else if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		o.MaxRSS = ru.Maxrss
+	}
+
+	// CPU 硬限制触发时内核直接发送 SIGKILL，需要根据用时判断
+	if sb.limits.CPUTime > 0 && o.CPUTime >= time.Duration(sb.limits.CPUTime)*time.Second {
+		o.TimedOut = true
+	}
+
+	if sb.cgroupDir != "" && cgroupOOMKilled(sb.cgroupDir) {
+		o.MemoryExceeded = true
+	}
+}
+
+// inspectSignal 记录终止信号，资源限制触发的信号转换为对应的判定
+func (sb *sandbox) inspectSignal(sig syscall.Signal, o *runOutcome) {
+	o.Signal = signalName(sig)
+	switch sig {
+	case syscall.SIGXCPU:
+		o.TimedOut = true
+	case syscall.SIGXFSZ:
+		o.OutputExceeded = true
+	}
+}
+
+// readStatus 读取隔离模式下 init 进程报告的退出状态，辅助进程异常结束（如超时被强制结束）时返回 false
+func (sb *sandbox) readStatus() (sandboxStatus, bool) {
+	var status sandboxStatus
+	if sb.status == nil {
+		return status, false
+	}
+	sb.statusW.Close()
+	data, err := io.ReadAll(sb.status)
+	if err != nil || len(data) == 0 {
+		return status, false
+	}
+	return status, json.Unmarshal(data, &status) == nil
+}
+
+// cleanup 释放沙箱资源
+func (sb *sandbox) cleanup() {
+	if sb.status != nil {
+		sb.status.Close()
+		sb.statusW.Close()
+		sb.status, sb.statusW = nil, nil
+	}
+	if sb.cgroupFD != nil {
+		sb.cgroupFD.Close()
+		sb.cgroupFD = nil
+	}
+	if sb.cgroupDir == "" {
+		return
+	}
+	// 结束残留进程后删除叶子节点
+	os.WriteFile(filepath.Join(sb.cgroupDir, "cgroup.kill"), []byte("1"), 0644)
+	for i := 0; i < 10; i++ {
+		if err := os.Remove(sb.cgroupDir); err == nil || os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	sb.cgroupDir = ""
+}
+
+// cgroupMemoryPeak 读取 cgroup 的峰值内存（字节），需要内核 5.19 及以上
+func cgroupMemoryPeak(dir string) (int64, bool) {
+	if dir == "" {
+		return 0, false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "memory.peak"))
+	if err != nil {
+		return 0, false
+	}
+	peak, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return peak, err == nil
+}
+
+// cgroupOOMKilled 检查 cgroup 中是否发生过 OOM kill
+func cgroupOOMKilled(dir string) bool {
+	file, err := os.Open(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" && fields[1] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// RunSandboxHelper 沙箱辅助进程入口：按参数完成隔离和资源限制后执行目标程序
+// 参数格式: <self> __cocode_sandbox__ <spec-json> <program> [args...]
+func RunSandboxHelper() {
+	runtime.LockOSThread()
+
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "sandbox: 参数不足")
+		os.Exit(127)
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(os.Args[2]), &spec); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: 解析参数失败: %v\n", err)
+		os.Exit(127)
+	}
+
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(127)
+	}
+
+	if spec.Root != "" {
+		fail(runSandboxInit(spec, os.Args[3:]))
+	}
+	if spec.Isolated {
+		syscall.CloseOnExec(sandboxExeFD)
+	}
+	if err := applyRlimits(spec.Limits); err != nil {
+		fail(err)
+	}
+	if spec.Isolated {
+		if err := installSeccomp(); err != nil {
+			fail(fmt.Errorf("安装 seccomp 过滤器失败: %v", err))
+		}
+	}
+
+	target := os.Args[3]
+	err := syscall.Exec(target, os.Args[3:], os.Environ())
+	fail(fmt.Errorf("执行程序失败: %v", err))
+}
+
+// applyRlimits 为当前进程设置资源限制（exec 后由目标程序继承）
+func applyRlimits(limits SandboxLimits) error {
+	set := func(resource int, name string, value uint64, extra uint64) error {
+		rl := syscall.Rlimit{Cur: value, Max: value + extra}
+		if err := syscall.Setrlimit(resource, &rl); err != nil {
+			return fmt.Errorf("设置 %s 失败: %v", name, err)
+		}
+		return nil
+	}
+
+	if err := set(syscall.RLIMIT_CORE, "RLIMIT_CORE", 0, 0); err != nil {
+		return err
+	}
+	if limits.CPUTime > 0 {
+		// 软限制到达时发送 SIGXCPU，再过 1 秒由硬限制强制结束
+		if err := set(syscall.RLIMIT_CPU, "RLIMIT_CPU", uint64(limits.CPUTime), 1); err != nil {
+			return err
+		}
+	}
+	if limits.FileSize > 0 {
+		if err := set(syscall.RLIMIT_FSIZE, "RLIMIT_FSIZE", uint64(limits.FileSize), 0); err != nil {
+			return err
+		}
+	}
+	if limits.OpenFiles > 0 {
+		if err := set(syscall.RLIMIT_NOFILE, "RLIMIT_NOFILE", uint64(limits.OpenFiles), 0); err != nil {
+			return err
+		}
+	}
+	if limits.Processes > 0 {
+		if err := set(rlimitNproc, "RLIMIT_NPROC", uint64(limits.Processes), 0); err != nil {
+			return err
+		}
+	}
+	if limits.Memory > 0 {
+		if err := set(syscall.RLIMIT_AS, "RLIMIT_AS", uint64(limits.Memory), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signalNames 常见终止信号名称
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGSYS:  "SIGSYS",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+// signalName 返回信号名称，未知信号返回编号
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return "SIG" + strconv.Itoa(int(sig))
+}
@@ -0,0 +1,46 @@
+//go:build !linux
+
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// sandbox 非 Linux 平台不支持资源限制，仅保留超时和输出限制
+type sandbox struct{}
+
+// prepareSandbox 非 Linux 平台直接运行程序
+func prepareSandbox(cmd *exec.Cmd, req runRequest) (*sandbox, error) {
+	if isolationBackend() != IsolationNone {
+		return nil, fmt.Errorf("当前平台不支持隔离方式: %s", isolationBackend())
+	}
+	return &sandbox{}, nil
+}
+
+// sandboxMountPoints 非 Linux 平台没有隔离模式，运行目录中没有被占用的名称
+func sandboxMountPoints() []string {
+	return nil
+}
+
+// killGroupOnCancel 非 Linux 平台取消时只结束命令本身
+func killGroupOnCancel(cmd *exec.Cmd) {}
+
+// inspect 根据进程退出状态填充运行结果
+func (sb *sandbox) inspect(state *os.ProcessState, o *runOutcome) {
+	if code := state.ExitCode(); code >= 0 {
+		o.ExitCode = code
+	} else {
+		o.Signal = state.String()
+	}
+}
+
+// cleanup 释放沙箱资源
+func (sb *sandbox) cleanup() {}
+
+// RunSandboxHelper 非 Linux 平台不支持沙箱辅助进程
+func RunSandboxHelper() {
+	fmt.Fprintln(os.Stderr, "sandbox: 当前平台不支持")
+	os.Exit(127)
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 诊断运行的编译参数和 Sanitizer 运行选项
+var (
+	sanitizerFlags = []string{"-fsanitize=address,undefined", "-g", "-fno-omit-frame-pointer"}
+	sanitizerEnv   = []string{
+		"ASAN_OPTIONS=detect_leaks=0:abort_on_error=0:print_summary=1",
+		"UBSAN_OPTIONS=print_stacktrace=1:halt_on_error=1:print_summary=1",
+	}
+)
+
+// 单次运行最多解析的报告数和每个报告保留的栈帧数
+const (
+	maxSanitizerReports = 10
+	maxStackFrames      = 32
+)
+
+// SanitizerLanguage 生成诊断运行使用的工具链：以 AddressSanitizer 和 UBSan 编译，不限制地址空间
+// 仅支持 GCC 和 Clang 编译的语言
+func SanitizerLanguage(lang *Language) (*Language, error) {
+	if !lang.NeedsCompile() || !isGCCOrClang(lang.CompileCommand[0]) {
+		return nil, fmt.Errorf("%s 不支持诊断运行", lang.DisplayName)
+	}
+	l, err := lang.withFlags(sanitizerFlags)
+	if err != nil {
+		return nil, fmt.Errorf("%s 不支持诊断运行", lang.DisplayName)
+	}
+	l.RunEnv = append(append([]string{}, lang.RunEnv...), sanitizerEnv...)
+	return l, nil
+}
+
+// isGCCOrClang 是否为 GCC 或 Clang 编译器（支持 -fsanitize、-g 等参数）
+func isGCCOrClang(compiler string) bool {
+	return isGCC(compiler) || strings.HasPrefix(filepath.Base(compiler), "clang")
+}
+
+// CompileAndDiagnose 以 Sanitizer 编译并运行代码，解析运行时检查的报告
+// lang 应为 SanitizerLanguage 返回的工具链
+func CompileAndDiagnose(lang *Language, code string, input string, opts RunOptions) *models.CompileResult {
+	b, result := compileSource(opts.context(), lang, code)
+	if b == nil {
+		return result
+	}
+	defer b.cleanup()
+
+	outcome := b.runInto(result, input, opts)
+	if outcome.Cancelled {
+		return result
+	}
+
+	stripDir := runDirStripper()
+	result.Message = stripDir(result.Message)
+	result.Sanitizer = parseSanitizerReports(stripDir(outcome.Stderr), lang)
+	symbolizeFrames(opts.context(), result.Sanitizer, filepath.Join(b.dir, executableName), lang, stripDir)
+	locateReports(result.Sanitizer)
+	if len(result.Sanitizer) == 0 {
+		result.Message += "\n\nSanitizer 未发现问题"
+		return result
+	}
+	result.Message += fmt.Sprintf("\n\nSanitizer 发现 %d 个问题:", len(result.Sanitizer))
+	for _, r := range result.Sanitizer {
+		result.Message += "\n  " + r.Kind
+		if r.File != "" {
+			result.Message += fmt.Sprintf(" (%s:%d)", r.File, r.Line)
+		}
+	}
+	return result
+}
+
+// runDirStripper 返回去掉路径中运行目录前缀的函数
+// 调试信息中是编译时运行目录的绝对路径（使用编译缓存时为缓存产物的编译目录），去掉后与编辑器中的文件名对应
+func runDirStripper() func(string) string {
+	dir, err := filepath.Abs(config.AppConfig.Compiler.TempDir)
+	if err != nil {
+		return func(s string) string { return s }
+	}
+	runDir := regexp.MustCompile(regexp.QuoteMeta(dir) + `/run_[^/]+/`)
+	return func(s string) string { return runDir.ReplaceAllString(s, "") }
+}
+
+var (
+	// asanHeaderPattern ==1234==ERROR: AddressSanitizer: heap-buffer-overflow on address ...
+	asanHeaderPattern = regexp.MustCompile(`^==\d+==ERROR: (AddressSanitizer|LeakSanitizer): (\S+)(.*)$`)
+	// ubsanHeaderPattern main.cpp:5:10: runtime error: signed integer overflow: ...
+	ubsanHeaderPattern = regexp.MustCompile(`^(.+?):(\d+):(\d+): runtime error: (.*)$`)
+	// stackFramePattern     #0 0x55d1c2a1b2c3 in main /path/main.cpp:5:10（没有符号时省略 in）
+	stackFramePattern = regexp.MustCompile(`^\s*#(\d+) 0x[0-9a-fA-F]+\s+(?:in )?(.*)$`)
+	// frameLocationPattern 栈帧末尾的 file:line[:column]
+	frameLocationPattern = regexp.MustCompile(`^(.*) (\S+):(\d+)(?::(\d+))?$`)
+	// buildIDPattern 栈帧末尾的 (BuildId: ...)
+	buildIDPattern = regexp.MustCompile(` \(BuildId: [0-9a-fA-F]+\)$`)
+)
+
+// ubsanKinds UBSan 报告说明中的关键字与错误类型
+var ubsanKinds = []struct {
+	keyword string
+	kind    string
+}{
+	{"signed integer overflow", "signed-integer-overflow"},
+	{"out of bounds", "out-of-bounds"},
+	{"division by zero", "division-by-zero"},
+	{"shift exponent", "shift"},
+	{"left shift of", "shift"},
+	{"null pointer", "null-pointer"},
+	{"misaligned address", "misaligned-access"},
+	{"is outside the range of representable values", "float-cast-overflow"},
+	{"not a valid value for type", "invalid-value"},
+	{"reached the end of a value-returning function", "missing-return"},
+	{"unreachable program point", "unreachable"},
+	{"pointer overflow", "pointer-overflow"},
+	{"insufficient space", "insufficient-object-size"},
+}
+
+// ubsanKind 根据 UBSan 报告说明判断错误类型
+func ubsanKind(message string) string {
+	for _, k := range ubsanKinds {
+		if strings.Contains(message, k.keyword) {
+			return k.kind
+		}
+	}
+	return "undefined-behavior"
+}
+
+// parseSanitizerReports 从程序的标准错误中解析 Sanitizer 报告，位于工作区文件中的栈帧视为用户代码
+func parseSanitizerReports(stderr string, lang *Language) []models.SanitizerReport {
+	var reports []models.SanitizerReport
+	var current *models.SanitizerReport
+	var raw []string
+	stackDone := false
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		current.Raw = strings.Join(raw, "\n")
+		reports = append(reports, *current)
+		current, raw = nil, nil
+	}
+
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := asanHeaderPattern.FindStringSubmatch(line); m != nil {
+			finish()
+			kind := m[2]
+			if m[1] == "LeakSanitizer" {
+				kind = "memory-leak"
+			}
+			current = &models.SanitizerReport{
+				Tool:    m[1],
+				Kind:    kind,
+				Message: m[1] + ": " + m[2] + m[3],
+				Frames:  []models.StackFrame{},
+			}
+			raw, stackDone = []string{line}, false
+			continue
+		}
+		if m := ubsanHeaderPattern.FindStringSubmatch(line); m != nil {
+			finish()
+			lineNo, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			current = &models.SanitizerReport{
+				Tool:    "UndefinedBehaviorSanitizer",
+				Kind:    ubsanKind(m[4]),
+				Message: m[4],
+				Frames:  []models.StackFrame{},
+			}
+			if lang.isUserSource(m[1]) {
+				current.File, current.Line, current.Column = strings.TrimPrefix(m[1], "./"), lineNo, column
+			}
+			raw, stackDone = []string{line}, false
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		raw = append(raw, line)
+
+		if m := stackFramePattern.FindStringSubmatch(line); m != nil {
+			// 只保留第一段调用栈（出错位置），之后的分配、释放位置仅保留在原文中
+			if !stackDone && len(current.Frames) < maxStackFrames {
+				current.Frames = append(current.Frames, parseStackFrame(m[1], m[2], lang))
+			}
+			continue
+		}
+		if len(current.Frames) > 0 {
+			stackDone = true
+		}
+		if strings.HasPrefix(line, "SUMMARY: ") {
+			finish()
+			if len(reports) >= maxSanitizerReports {
+				break
+			}
+		}
+	}
+	finish()
+
+	if len(reports) > maxSanitizerReports {
+		reports = reports[:maxSanitizerReports]
+	}
+	return reports
+}
+
+// locateReports 未给出位置的报告以调用栈中第一个用户代码栈帧为出错位置
+func locateReports(reports []models.SanitizerReport) {
+	for i := range reports {
+		r := &reports[i]
+		if r.File != "" {
+			continue
+		}
+		for _, f := range r.Frames {
+			if f.User {
+				r.File, r.Line, r.Column = f.File, f.Line, f.Column
+				break
+			}
+		}
+	}
+}
+
+// moduleOffsetPattern 未符号化栈帧中的 模块+偏移
+var moduleOffsetPattern = regexp.MustCompile(`^(.*)\+(0x[0-9a-fA-F]+)$`)
+
+// symbolizeFrames 用 addr2line 解析用户程序中未符号化的栈帧
+// Sanitizer 借助 llvm-symbolizer 解析栈帧，服务器未安装或沙箱中无法运行该工具时栈帧只有 模块+偏移
+func symbolizeFrames(ctx context.Context, reports []models.SanitizerReport, exe string, lang *Language, stripDir func(string) string) {
+	var frames []*models.StackFrame
+	var addrs []string
+	for i := range reports {
+		for j := range reports[i].Frames {
+			f := &reports[i].Frames[j]
+			m := moduleOffsetPattern.FindStringSubmatch(f.File)
+			if f.Line > 0 || m == nil || (m[1] != "/proc/self/exe" && filepath.Base(m[1]) != executableName) {
+				continue
+			}
+			frames = append(frames, f)
+			addrs = append(addrs, m[2])
+		}
+	}
+	if len(frames) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	args := append([]string{"-f", "-C", "-e", exe}, addrs...)
+	out, err := exec.CommandContext(ctx, "addr2line", args...).Output()
+	if err != nil {
+		return
+	}
+	// 每个地址输出两行：函数名、file:line
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	for i, f := range frames {
+		if 2*i+1 >= len(lines) {
+			break
+		}
+		if fn := lines[2*i]; fn != "??" {
+			f.Function = fn
+		}
+		loc := strings.SplitN(lines[2*i+1], " ", 2)[0] // 去掉 (discriminator N)
+		k := strings.LastIndex(loc, ":")
+		if k < 0 || strings.HasPrefix(loc, "??") {
+			continue
+		}
+		line, err := strconv.Atoi(loc[k+1:])
+		if err != nil || line == 0 {
+			continue
+		}
+		f.File, f.Line = stripDir(loc[:k]), line
+		f.User = lang.isUserSource(f.File)
+	}
+}
+
+// parseStackFrame 解析栈帧的函数名和源代码位置
+func parseStackFrame(index, rest string, lang *Language) models.StackFrame {
+	n, _ := strconv.Atoi(index)
+	frame := models.StackFrame{Index: n}
+	rest = buildIDPattern.ReplaceAllString(rest, "")
+	if m := frameLocationPattern.FindStringSubmatch(rest); m != nil {
+		frame.Function = m[1]
+		frame.File = m[2]
+		frame.Line, _ = strconv.Atoi(m[3])
+		frame.Column, _ = strconv.Atoi(m[4])
+		frame.User = lang.isUserSource(m[2])
+		return frame
+	}
+	// 没有调试信息: func (/path/to/binary+0x1234)，没有符号时只有括号部分
+	switch i := strings.LastIndex(rest, " ("); {
+	case i >= 0:
+		frame.Function = rest[:i]
+		frame.File = strings.Trim(rest[i+2:], "()")
+	case strings.HasPrefix(rest, "("):
+		frame.File = strings.Trim(rest, "()")
+	default:
+		frame.Function = rest
+	}
+	return frame
+}
@@ -0,0 +1,118 @@
+//go:build linux
+
+package services
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// seccomp / prctl 相关常量（syscall 包未导出）
+const (
+	prSetNoNewPrivs   = 38
+	prCapAmbient      = 47
+	prCapAmbientClear = 4
+	seccompModeFilter = 2
+
+	seccompRetAllow       = 0x7fff0000
+	seccompRetErrno       = 0x00050000
+	seccompRetKillProcess = 0x80000000
+
+	bpfLdWAbs  = syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS
+	bpfJeqK    = syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K
+	bpfJsetK   = syscall.BPF_JMP | syscall.BPF_JSET | syscall.BPF_K
+	bpfRetK    = syscall.BPF_RET | syscall.BPF_K
+	seccompNr  = 0 // seccomp_data.nr 偏移
+	seccompArc = 4 // seccomp_data.arch 偏移
+)
+
+// commonAllowedSyscalls 各架构通用的系统调用白名单
+// 覆盖动态链接、内存分配、标准输入输出、线程和计时；网络、挂载、ptrace 等均不在其中
+var commonAllowedSyscalls = []uintptr{
+	syscall.SYS_READ, syscall.SYS_WRITE, syscall.SYS_READV, syscall.SYS_WRITEV,
+	syscall.SYS_PREAD64, syscall.SYS_PWRITE64, syscall.SYS_LSEEK, syscall.SYS_CLOSE,
+	syscall.SYS_OPENAT, syscall.SYS_FSTAT, syscall.SYS_FACCESSAT, syscall.SYS_READLINKAT,
+	syscall.SYS_GETDENTS64, syscall.SYS_GETCWD, syscall.SYS_FCNTL, syscall.SYS_IOCTL,
+	syscall.SYS_DUP, syscall.SYS_DUP3, syscall.SYS_PIPE2, syscall.SYS_PPOLL, syscall.SYS_PSELECT6,
+	syscall.SYS_FTRUNCATE, syscall.SYS_FSYNC, syscall.SYS_FADVISE64,
+	syscall.SYS_MKDIRAT, syscall.SYS_UNLINKAT,
+	syscall.SYS_EPOLL_CREATE1, syscall.SYS_EPOLL_CTL, syscall.SYS_EPOLL_PWAIT, syscall.SYS_EVENTFD2,
+	syscall.SYS_BRK, syscall.SYS_MMAP, syscall.SYS_MUNMAP, syscall.SYS_MPROTECT, syscall.SYS_MREMAP,
+	syscall.SYS_MADVISE, syscall.SYS_MSYNC, syscall.SYS_MINCORE,
+	syscall.SYS_RT_SIGACTION, syscall.SYS_RT_SIGPROCMASK, syscall.SYS_RT_SIGRETURN, syscall.SYS_SIGALTSTACK,
+	syscall.SYS_KILL, syscall.SYS_TKILL, syscall.SYS_TGKILL,
+	syscall.SYS_EXECVE, syscall.SYS_CLONE, syscall.SYS_WAIT4,
+	syscall.SYS_EXIT, syscall.SYS_EXIT_GROUP,
+	syscall.SYS_FUTEX, syscall.SYS_SET_TID_ADDRESS, syscall.SYS_SET_ROBUST_LIST, syscall.SYS_GET_ROBUST_LIST,
+	syscall.SYS_SCHED_YIELD, syscall.SYS_SCHED_GETAFFINITY, syscall.SYS_SCHED_GETPARAM, syscall.SYS_SCHED_GETSCHEDULER,
+	syscall.SYS_NANOSLEEP, syscall.SYS_CLOCK_GETTIME, syscall.SYS_CLOCK_GETRES, syscall.SYS_CLOCK_NANOSLEEP,
+	syscall.SYS_GETTIMEOFDAY, syscall.SYS_TIMES,
+	syscall.SYS_GETPID, syscall.SYS_GETTID, syscall.SYS_GETPPID,
+	syscall.SYS_GETUID, syscall.SYS_GETGID, syscall.SYS_GETEUID, syscall.SYS_GETEGID,
+	syscall.SYS_GETRESUID, syscall.SYS_GETRESGID,
+	syscall.SYS_GETRLIMIT, syscall.SYS_SETRLIMIT, syscall.SYS_PRLIMIT64, syscall.SYS_GETRUSAGE,
+	syscall.SYS_UNAME, syscall.SYS_SYSINFO,
+}
+
+// installSeccomp 为当前线程安装系统调用白名单过滤器（exec 后继承）
+// 白名单之外的调用返回 EPERM；clone3 返回 ENOSYS 以便 libc 回退到 clone
+func installSeccomp() error {
+	if seccompArch == 0 {
+		return errors.New("当前架构不支持 seccomp 过滤")
+	}
+
+	allowed := append(append([]uintptr{}, commonAllowedSyscalls...), archAllowedSyscalls...)
+
+	filter := []syscall.SockFilter{
+		// 校验架构，防止通过其他 ABI 绕过
+		{Code: bpfLdWAbs, K: seccompArc},
+		{Code: bpfJeqK, Jt: 1, K: seccompArch},
+		{Code: bpfRetK, K: seccompRetKillProcess},
+		{Code: bpfLdWAbs, K: seccompNr},
+		// x32 系统调用号带有 0x40000000 标记
+		{Code: bpfJsetK, Jt: 0, Jf: 1, K: 0x40000000},
+		{Code: bpfRetK, K: seccompRetKillProcess},
+		{Code: bpfJeqK, Jt: 0, Jf: 1, K: sysClone3},
+		{Code: bpfRetK, K: seccompRetErrno | uint32(syscall.ENOSYS)},
+	}
+	for _, nr := range allowed {
+		filter = append(filter,
+			syscall.SockFilter{Code: bpfJeqK, Jt: 0, Jf: 1, K: uint32(nr)},
+			syscall.SockFilter{Code: bpfRetK, K: seccompRetAllow},
+		)
+	}
+	filter = append(filter, syscall.SockFilter{Code: bpfRetK, K: seccompRetErrno | uint32(syscall.EPERM)})
+
+	prog := syscall.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+	if err := prctl(prSetNoNewPrivs, 1, 0); err != nil {
+		return err
+	}
+	return prctl(syscall.PR_SET_SECCOMP, seccompModeFilter, uintptr(unsafe.Pointer(&prog)))
+}
+
+// dropCapabilities 清空能力边界集和环境能力集，exec 后目标程序不再持有任何能力
+func dropCapabilities() error {
+	for cap := uintptr(0); cap < 64; cap++ {
+		if err := prctl(syscall.PR_CAPBSET_DROP, cap, 0); err != nil {
+			if err == syscall.EINVAL {
+				break
+			}
+			return err
+		}
+	}
+	if err := prctl(prCapAmbient, prCapAmbientClear, 0); err != nil && err != syscall.EINVAL {
+		return err
+	}
+	return nil
+}
+
+func prctl(option, arg2, arg3 uintptr) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, option, arg2, arg3); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build linux && amd64
+
+package services
+
+import "syscall"
+
+// AUDIT_ARCH_X86_64
+const seccompArch = 0xc000003e
+
+const sysClone3 = 435
+
+// archAllowedSyscalls amd64 特有的系统调用（含旧接口和 syscall 包未收录的编号）
+var archAllowedSyscalls = []uintptr{
+	syscall.SYS_OPEN, syscall.SYS_STAT, syscall.SYS_LSTAT, syscall.SYS_NEWFSTATAT,
+	syscall.SYS_ACCESS, syscall.SYS_READLINK, syscall.SYS_POLL, syscall.SYS_SELECT,
+	syscall.SYS_PIPE, syscall.SYS_DUP2, syscall.SYS_EPOLL_WAIT,
+	syscall.SYS_MKDIR, syscall.SYS_UNLINK, syscall.SYS_RENAME,
+	syscall.SYS_ARCH_PRCTL, syscall.SYS_TIME, syscall.SYS_GETPGRP,
+	syscall.SYS_FORK, syscall.SYS_VFORK,
+	318, // getrandom
+	324, // membarrier
+	332, // statx
+	334, // rseq
+	439, // faccessat2
+}
@@ -0,0 +1,19 @@
+//go:build linux && arm64
+
+package services
+
+import "syscall"
+
+// AUDIT_ARCH_AARCH64
+const seccompArch = 0xc00000b7
+
+const sysClone3 = 435
+
+// archAllowedSyscalls arm64 特有的系统调用（含 syscall 包未收录的编号）
+var archAllowedSyscalls = []uintptr{
+	syscall.SYS_FSTATAT, syscall.SYS_GETRANDOM,
+	283, // membarrier
+	291, // statx
+	293, // rseq
+	439, // faccessat2
+}
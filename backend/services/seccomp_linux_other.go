@@ -0,0 +1,10 @@
+//go:build linux && !amd64 && !arm64
+
+package services
+
+// 其他架构暂未提供系统调用白名单，命名空间隔离模式下会拒绝运行
+const seccompArch = 0
+
+const sysClone3 = 435
+
+var archAllowedSyscalls []uintptr
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"cocode/backend/config"
+)
+
+// 默认的流式推送参数
+const (
+	defaultStreamInterval = 200 * time.Millisecond
+	defaultStreamLimit    = 64 * 1024
+)
+
+// 输出流名称
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// OutputFunc 运行中输出的回调，stream 为 stdout 或 stderr
+type OutputFunc func(stream, data string)
+
+// outputStreamer 将运行中的输出按固定间隔合并后推送，累计超过上限后不再推送
+type outputStreamer struct {
+	mu        sync.Mutex
+	pending   map[string][]byte
+	order     []string // 各输出流首次出现的顺序
+	sent      int
+	limit     int
+	truncated bool
+	emit      OutputFunc
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newOutputStreamer 创建并启动输出推送器，使用完毕后需调用 Close
+func newOutputStreamer(emit OutputFunc) *outputStreamer {
+	interval := streamInterval()
+	limit := config.AppConfig.Compiler.StreamLimit * 1024
+	if limit <= 0 {
+		limit = defaultStreamLimit
+	}
+
+	s := &outputStreamer{
+		pending: make(map[string][]byte),
+		limit:   limit,
+		emit:    emit,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.loop(interval)
+	return s
+}
+
+// streamInterval 实时推送的间隔
+func streamInterval() time.Duration {
+	if ms := config.AppConfig.Compiler.StreamInterval; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return defaultStreamInterval
+}
+
+// Writer 返回写入指定输出流的 io.Writer
+func (s *outputStreamer) Writer(stream string) *streamWriter {
+	return &streamWriter{streamer: s, stream: stream}
+}
+
+// Close 推送剩余内容并停止
+func (s *outputStreamer) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *outputStreamer) loop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(false)
+		case <-s.stop:
+			s.flush(true)
+			return
+		}
+	}
+}
+
+func (s *outputStreamer) write(stream string, p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.truncated {
+		return
+	}
+	if _, ok := s.pending[stream]; !ok {
+		s.order = append(s.order, stream)
+	}
+	s.pending[stream] = append(s.pending[stream], p...)
+}
+
+// flush 推送缓存的内容；未到结束时保留末尾不完整的 UTF-8 字符
+func (s *outputStreamer) flush(final bool) {
+	type chunk struct{ stream, data string }
+	var chunks []chunk
+
+	s.mu.Lock()
+	for _, stream := range s.order {
+		data := s.pending[stream]
+		if len(data) == 0 {
+			continue
+		}
+		send := data
+		if !final {
+			send = data[:validUTF8Prefix(data)]
+		}
+		if s.sent+len(send) > s.limit {
+			send = send[:validUTF8Prefix(send[:s.limit-s.sent])]
+			s.truncated = true
+		}
+		s.sent += len(send)
+		s.pending[stream] = data[len(send):]
+		if len(send) > 0 {
+			chunks = append(chunks, chunk{stream, string(send)})
+		}
+		if s.truncated {
+			chunks = append(chunks, chunk{stream, "\n...(输出过多，停止实时推送)\n"})
+			s.pending = map[string][]byte{}
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range chunks {
+		s.emit(c.stream, c.data)
+	}
+}
+
+// validUTF8Prefix 返回不以不完整 UTF-8 字符结尾的最长前缀长度
+func validUTF8Prefix(data []byte) int {
+	for back := 0; back < utf8.UTFMax && back < len(data); back++ {
+		i := len(data) - 1 - back
+		if !utf8.RuneStart(data[i]) {
+			continue
+		}
+		if utf8.FullRune(data[i:]) {
+			return len(data)
+		}
+		return i
+	}
+	return len(data)
+}
+
+// streamWriter 输出流写入器
+type streamWriter struct {
+	streamer *outputStreamer
+	stream   string
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.streamer.write(w.stream, p)
+	return len(p), nil
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// 对拍结果状态
+const (
+	StressFound     = "found"     // 找到反例
+	StressPassed    = "passed"    // 达到轮数或时间上限，未发现差异
+	StressError     = "error"     // 编译失败或辅助程序运行出错
+	StressCancelled = "cancelled" // 被取消
+)
+
+// 未配置 [stress] 时的默认上限
+const (
+	defaultStressIterations = 1000
+	defaultStressTimeLimit  = 60 * time.Second
+)
+
+// StressOptions 对拍选项
+type StressOptions struct {
+	Iterations int                                        // 最多运行的轮数，0 或超过配置上限时使用上限
+	Seed       int                                        // 第一轮的随机种子，之后每轮加一
+	OnProgress func(iteration int, elapsed time.Duration) // 进度回调，按推送间隔节流，可为空
+	Ctx        context.Context                            // 取消对拍的上下文，为空时不可取消
+}
+
+// stressIterations 本次对拍的轮数上限
+func stressIterations(requested int) int {
+	limit := config.AppConfig.Stress.MaxIterations
+	if limit <= 0 {
+		limit = defaultStressIterations
+	}
+	if requested > 0 && requested < limit {
+		return requested
+	}
+	return limit
+}
+
+// stressTimeLimit 对拍的时间上限
+func stressTimeLimit() time.Duration {
+	if s := config.AppConfig.Stress.TimeLimit; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return defaultStressTimeLimit
+}
+
+// ValidateStressProgram 校验对拍的辅助程序
+func ValidateStressProgram(name string, p models.StressProgram) error {
+	if strings.TrimSpace(p.Code) == "" {
+		return fmt.Errorf("%s代码不能为空", name)
+	}
+	_, err := GetLanguage(p.Language)
+	return err
+}
+
+// RunStress 对拍：编译数据生成器、暴力解法和解法后逐轮运行
+// 每轮以种子作为生成器的命令行参数生成输入，用检查器比对暴力解法与解法的输出，
+// 直到出现差异、解法运行出错，或达到轮数、时间上限
+func RunStress(lang *Language, code string, generator, brute models.StressProgram, checkerCfg models.CheckerConfig, opts StressOptions) *models.StressResult {
+	ctx := opts.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := &models.StressResult{}
+
+	checker, err := NewChecker(ctx, checkerCfg)
+	if err != nil {
+		result.Status = StressError
+		result.Message = "检查器错误: " + err.Error()
+		return result
+	}
+	defer checker.Close()
+
+	genLang, _ := GetLanguage(generator.Language)
+	bruteLang, _ := GetLanguage(brute.Language)
+	programs := []struct {
+		name string
+		lang *Language
+		code string
+	}{
+		{"数据生成器", genLang, generator.Code},
+		{"暴力解法", bruteLang, brute.Code},
+		{"解法", lang, code},
+	}
+	builds := make([]*build, 0, len(programs))
+	defer func() {
+		for _, b := range builds {
+			b.cleanup()
+		}
+	}()
+	for _, p := range programs {
+		b, compiled := compileSource(ctx, p.lang, p.code)
+		if b == nil {
+			result.Status = StressError
+			if compiled.Verdict == models.VerdictCancelled {
+				result.Status = StressCancelled
+			}
+			result.Message = p.name + compiled.Message
+			return result
+		}
+		builds = append(builds, b)
+	}
+	genBuild, bruteBuild, solBuild := builds[0], builds[1], builds[2]
+
+	iterations := stressIterations(opts.Iterations)
+	limit := stressTimeLimit()
+	interval := streamInterval()
+	start := time.Now()
+	lastProgress := start
+
+	finish := func(status, message string) *models.StressResult {
+		result.Status = status
+		result.Elapsed = time.Since(start).Milliseconds()
+		result.Message = message
+		return result
+	}
+
+	for i := 0; i < iterations; i++ {
+		if ctx.Err() != nil {
+			return finish(StressCancelled, fmt.Sprintf("对拍已取消: 已运行 %d 轮", result.Iterations))
+		}
+		if time.Since(start) > limit {
+			return finish(StressPassed, fmt.Sprintf("达到时间上限（%v），运行 %d 轮未发现差异", limit, result.Iterations))
+		}
+		seed := opts.Seed + i
+
+		gen := runStressProgram(ctx, genBuild, "", strconv.Itoa(seed))
+		if gen.Cancelled {
+			continue
+		}
+		if gen.Verdict() != models.VerdictOK {
+			return finish(StressError, fmt.Sprintf("数据生成器在种子 %d 上运行失败\n%s", seed, describeOutcome(gen)))
+		}
+		input := gen.Stdout
+
+		expected := runStressProgram(ctx, bruteBuild, input)
+		if expected.Cancelled {
+			continue
+		}
+		if expected.Verdict() != models.VerdictOK {
+			result.Seed, result.Input = seed, input
+			return finish(StressError, fmt.Sprintf("暴力解法在种子 %d 上运行失败\n%s", seed, describeOutcome(expected)))
+		}
+
+		actual := runStressProgram(ctx, solBuild, input)
+		if actual.Cancelled {
+			continue
+		}
+		result.Iterations = i + 1
+
+		verdict, diff := actual.Verdict(), ""
+		if verdict == models.VerdictOK {
+			verdict, diff = checker.Check(input, actual.Stdout, expected.Stdout)
+		} else if verdict == models.VerdictRE {
+			diff = describeOutcome(actual)
+		}
+		switch verdict {
+		case models.VerdictAC:
+		case models.VerdictFail:
+			return finish(StressError, "检查器错误: "+diff)
+		default:
+			result.Seed, result.Verdict, result.Diff = seed, verdict, diff
+			result.Input, result.Expected, result.Output = input, expected.Stdout, actual.Stdout
+			return finish(StressFound, fmt.Sprintf("第 %d 轮（种子 %d）找到反例: %s", result.Iterations, seed,
+				strings.TrimSpace(verdict+" "+verdictText[verdict])))
+		}
+
+		if opts.OnProgress != nil && time.Since(lastProgress) >= interval {
+			lastProgress = time.Now()
+			opts.OnProgress(result.Iterations, time.Since(start))
+		}
+	}
+	return finish(StressPassed, fmt.Sprintf("运行 %d 轮未发现差异", result.Iterations))
+}
+
+// runStressProgram 以指定输入和命令行参数运行对拍中的程序
+func runStressProgram(ctx context.Context, b *build, input string, args ...string) *runOutcome {
+	req := b.command(strings.NewReader(input), args...)
+	req.Ctx = ctx
+	return runSandboxed(req)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"cocode/backend/config"
+	"cocode/backend/models"
+)
+
+// defaultSyntaxCheckDelay 编辑停止后到开始语法检查的默认等待时间
+const defaultSyntaxCheckDelay = 800 * time.Millisecond
+
+// SyntaxCheckResult 一次后台语法检查的结果
+type SyntaxCheckResult struct {
+	Version     int                 `json:"version"`  // 检查的代码版本
+	Language    string              `json:"language"` // 检查时的语言
+	Diagnostics []models.Diagnostic `json:"diagnostics"`
+}
+
+// SyntaxChecker 后台语法检查：代码编辑停止一段时间后检查最新版本，新版本到来时取消旧的检查
+// 语法检查不经过评测队列，也不计入用户的运行配额
+type SyntaxChecker struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel context.CancelFunc
+	last   *SyntaxCheckResult
+}
+
+// NewSyntaxChecker 创建后台语法检查器
+func NewSyntaxChecker() *SyntaxChecker {
+	return &SyntaxChecker{}
+}
+
+// syntaxCheckEnabled 是否开启后台语法检查
+func syntaxCheckEnabled() bool {
+	return config.AppConfig.Compiler.SyntaxCheck
+}
+
+// syntaxCheckDelay 编辑停止后到开始检查的等待时间
+func syntaxCheckDelay() time.Duration {
+	if ms := config.AppConfig.Compiler.SyntaxCheckDelay; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return defaultSyntaxCheckDelay
+}
+
+// Schedule 安排检查指定版本的代码，取消尚未完成的旧检查；检查完成后调用 onResult
+func (c *SyntaxChecker) Schedule(state models.CodeState, onResult func(*SyntaxCheckResult)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopLocked()
+	if !syntaxCheckEnabled() {
+		return
+	}
+	lang, err := GetLanguage(state.Language)
+	if err != nil || len(lang.DiagnosticsCommand) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.timer = time.AfterFunc(syntaxCheckDelay(), func() {
+		diags, ok := runSyntaxCheck(ctx, lang.WithFiles(SourceFiles(state)), state.Code)
+		if !ok {
+			return
+		}
+		result := &SyntaxCheckResult{Version: state.Version, Language: lang.Name, Diagnostics: diags}
+
+		c.mu.Lock()
+		if ctx.Err() != nil {
+			// 检查期间已有新版本
+			c.mu.Unlock()
+			return
+		}
+		c.last = result
+		c.mu.Unlock()
+		onResult(result)
+	})
+}
+
+// Last 最近一次完成的检查结果，没有时返回 nil
+func (c *SyntaxChecker) Last() *SyntaxCheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// stopLocked 停止等待中的检查并取消正在进行的检查（需持有锁）
+func (c *SyntaxChecker) stopLocked() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+}
+
+// runSyntaxCheck 在临时目录中运行诊断命令检查代码，被取消时返回 false
+func runSyntaxCheck(ctx context.Context, lang *Language, code string) ([]models.Diagnostic, bool) {
+	tempDir := config.AppConfig.Compiler.TempDir
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, false
+	}
+	dir, err := os.MkdirTemp(tempDir, "check_")
+	if err != nil {
+		return nil, false
+	}
+	defer os.RemoveAll(dir)
+
+	if err := writeSources(dir, lang, code); err != nil {
+		return nil, false
+	}
+
+	output, failed, ok := runDiagnosticsCommand(ctx, lang, dir)
+	if !ok {
+		return nil, false
+	}
+	// 检查失败却没有结构化诊断时（如编译器以文本报告致命错误），解析文本输出
+	diags, ok := parseDiagnostics(output)
+	if !ok || (failed && len(diags) == 0) {
+		diags = parseTextDiagnostics(string(output))
+	}
+	if diags == nil {
+		diags = []models.Diagnostic{}
+	}
+	return diags, true
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"cocode/backend/models"
+)
+
+// terminalDrainTimeout 程序结束后等待读取剩余终端输出的最长时间
+const terminalDrainTimeout = time.Second
+
+// terminalInputBuffer 等待写入伪终端的输入条数上限，程序不读取输入时超出部分被拒绝
+const terminalInputBuffer = 64
+
+// TerminalSession 伪终端运行会话，程序的标准输入输出连接到伪终端
+type TerminalSession struct {
+	Owner      *Client // 发起运行的客户端
+	ShareInput bool    // 是否允许房间内所有成员输入
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	master *os.File    // 伪终端主设备，程序运行期间有效
+	input  chan string // 待写入伪终端的输入，由 Run 中的写入协程消费
+}
+
+// NewTerminalSession 创建终端运行会话
+// ctx 取消时（如运行被取消）结束会话
+func NewTerminalSession(ctx context.Context, owner *Client, shareInput bool) *TerminalSession {
+	ctx, cancel := context.WithCancel(ctx)
+	return &TerminalSession{
+		Owner:      owner,
+		ShareInput: shareInput,
+		ctx:        ctx,
+		cancel:     cancel,
+		input:      make(chan string, terminalInputBuffer),
+	}
+}
+
+// CanWrite 判断客户端是否可以向程序输入
+func (s *TerminalSession) CanWrite(client *Client) bool {
+	return s.ShareInput || client.Username == s.Owner.Username
+}
+
+// Write 将输入交给写入协程，不等待程序读取；缓冲已满时拒绝本次输入
+func (s *TerminalSession) Write(input string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.master == nil {
+		return errors.New("程序尚未启动或已结束")
+	}
+	select {
+	case s.input <- input:
+		return nil
+	default:
+		return errors.New("程序暂未读取输入，本次输入已丢弃")
+	}
+}
+
+// Close 结束会话，正在运行的程序会被终止
+func (s *TerminalSession) Close() {
+	s.cancel()
+}
+
+// Run 编译代码并在伪终端中运行，阻塞直到程序结束
+// 程序启动后调用 onStart；终端输出（含回显）通过 onOutput 推送
+func (s *TerminalSession) Run(lang *Language, code string, onStart func(), onOutput OutputFunc) *models.CompileResult {
+	defer s.cancel()
+
+	b, result := compileSource(s.ctx, lang, code)
+	if b == nil {
+		return result
+	}
+	defer b.cleanup()
+
+	if s.ctx.Err() != nil {
+		result.Verdict = models.VerdictCancelled
+		result.Message += "\n运行已取消"
+		return result
+	}
+
+	master, slave, err := openPTY()
+	if err != nil {
+		result.Verdict = models.VerdictRE
+		result.Message += "\n" + err.Error()
+		return result
+	}
+	defer master.Close()
+
+	// 读取终端输出，超出输出限制时终止程序
+	output := &limitedBuffer{limit: outputLimitBytes(), onExceed: s.cancel}
+	streamer := newOutputStreamer(onOutput)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		buf := make([]byte, 4096)
+		for {
+			n, err := master.Read(buf)
+			if n > 0 {
+				output.Write(buf[:n])
+				streamer.Writer(StreamStdout).Write(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// 写入伪终端可能因程序不读取输入而阻塞，由单独的协程进行，不占用会话锁和客户端的读取循环
+	stopInput := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case input := <-s.input:
+				if _, err := master.WriteString(input); err != nil {
+					return
+				}
+			case <-stopInput:
+				return
+			}
+		}
+	}()
+
+	s.mu.Lock()
+	s.master = master
+	s.mu.Unlock()
+	if onStart != nil {
+		onStart()
+	}
+
+	req := b.command(nil)
+	req.Ctx = s.ctx
+	req.Terminal = slave
+	req.closeAfterStart = []io.Closer{slave}
+	outcome := runSandboxed(req)
+
+	s.mu.Lock()
+	s.master = nil
+	s.mu.Unlock()
+	close(stopInput)
+
+	// 从设备全部关闭后读取会返回错误；后台进程仍占用终端时超时放弃
+	select {
+	case <-drained:
+	case <-time.After(terminalDrainTimeout):
+		master.Close()
+		<-drained
+	}
+	streamer.Close()
+
+	outcome.Stdout = output.String()
+	if output.Exceeded() {
+		// 超出输出限制时由会话自身结束程序，不算作取消
+		outcome.OutputExceeded = true
+		outcome.Cancelled = false
+	}
+
+	result.Verdict = outcome.Verdict()
+	result.Signal = outcome.Signal
+	result.ExitCode = outcome.ExitCode
+	result.Output = outcome.Stdout
+	result.Success = result.Verdict == models.VerdictOK
+	result.Stats = outcome.stats()
+	result.Message += "\n" + describeOutcome(outcome) + describeStats(result.Stats)
+	return result
+}
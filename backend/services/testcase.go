@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cocode/backend/models"
+)
+
+// RunTests 编译一次后依次运行所有测试用例，并用检查器比对期望输出
+// 编译失败时返回的结果列表为空，编译日志在 CompileResult 中
+func RunTests(lang *Language, code string, cases []models.TestCase, checkerCfg models.CheckerConfig, opts RunOptions) (*models.CompileResult, []models.TestCaseResult) {
+	ctx := opts.context()
+	checker, err := NewChecker(ctx, checkerCfg)
+	if err != nil {
+		return &models.CompileResult{Verdict: models.VerdictFail, Message: "检查器错误: " + err.Error()}, nil
+	}
+	defer checker.Close()
+
+	b, result := compileSource(ctx, lang, code)
+	if b == nil {
+		return result, nil
+	}
+	defer b.cleanup()
+
+	results := make([]models.TestCaseResult, 0, len(cases))
+	total := &models.RunStats{} // 各用例用时之和与最大内存
+	result.Stats = total
+	passed := 0
+	verdict := models.VerdictOK // 总体判定: 第一个未通过用例的判定，全部通过且有比对时为 AC
+	for _, tc := range cases {
+		if ctx.Err() != nil {
+			result.Verdict = models.VerdictCancelled
+			result.Message += fmt.Sprintf("\n测试已取消: 已运行 %d/%d", len(results), len(cases))
+			return result, results
+		}
+		caseResult, outcome := runTestCase(ctx, b, checker, tc)
+		if s := outcome.stats(); s != nil {
+			total.UserTime += s.UserTime
+			total.SysTime += s.SysTime
+			total.WallTime += s.WallTime
+			total.MaxRSS = max(total.MaxRSS, s.MaxRSS)
+		}
+		switch {
+		case caseResult.Verdict == models.VerdictAC:
+			passed++
+			if verdict == models.VerdictOK {
+				verdict = models.VerdictAC
+			}
+		case caseResult.Verdict == models.VerdictOK:
+			passed++
+		case passed == len(results):
+			verdict = caseResult.Verdict
+		}
+		results = append(results, caseResult)
+	}
+
+	result.Verdict = verdict
+	result.Success = passed == len(cases)
+	result.Message += fmt.Sprintf("\n测试完成: 通过 %d/%d", passed, len(cases)) + describeStats(total)
+	if lang.Coverage {
+		// 各用例的执行次数累加在同一份 .gcda 中
+		b.collectCoverage(ctx, result)
+	}
+	return result, results
+}
+
+// runTestCase 运行单个测试用例并检查输出
+func runTestCase(ctx context.Context, b *build, checker *Checker, tc models.TestCase) (models.TestCaseResult, *runOutcome) {
+	req := b.command(strings.NewReader(tc.Input))
+	req.Ctx = ctx
+	outcome := runSandboxed(req)
+
+	caseResult := models.TestCaseResult{
+		ID:      tc.ID,
+		Name:    tc.Name,
+		Verdict: outcome.Verdict(),
+		Time:    outcome.CPUTime.Milliseconds(),
+		Memory:  outcome.MaxRSS,
+		Output:  outcome.Stdout,
+	}
+
+	if caseResult.Verdict != models.VerdictOK {
+		if caseResult.Verdict == models.VerdictRE {
+			caseResult.Diff = describeOutcome(outcome)
+		}
+		return caseResult, outcome
+	}
+
+	// 未填写期望输出的用例只运行不比对
+	if tc.Expected == "" {
+		return caseResult, outcome
+	}
+
+	caseResult.Verdict, caseResult.Diff = checker.Check(tc.Input, outcome.Stdout, tc.Expected)
+	return caseResult, outcome
+}
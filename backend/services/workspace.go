@@ -0,0 +1,163 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"cocode/backend/models"
+)
+
+// 工作区限制
+const (
+	maxWorkspaceFiles  = 32  // 工作区最多的文件数
+	maxFilePathLength  = 128 // 文件路径的最大长度
+	maxFilePathSegment = 4   // 路径最多的层数
+)
+
+// filePathPattern 允许的文件路径：以 / 分隔的字母、数字、下划线、点、加号和减号
+var filePathPattern = regexp.MustCompile(`^[A-Za-z0-9_.+-]+(/[A-Za-z0-9_.+-]+)*$`)
+
+// translationUnitExts 与主文件一同编译的源文件扩展名，按主文件扩展名分组
+var translationUnitExts = map[string][]string{
+	".cpp": {".cpp", ".cc", ".cxx"},
+	".cc":  {".cpp", ".cc", ".cxx"},
+	".cxx": {".cpp", ".cc", ".cxx"},
+	".c":   {".c"},
+}
+
+// CleanFilePath 校验并规范化工作区中的文件路径
+func CleanFilePath(path string) (string, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "./")
+	if path == "" {
+		return "", errors.New("文件路径不能为空")
+	}
+	if len(path) > maxFilePathLength {
+		return "", fmt.Errorf("文件路径不能超过 %d 个字符", maxFilePathLength)
+	}
+	if !filePathPattern.MatchString(path) {
+		return "", errors.New("文件路径只能包含字母、数字、下划线、点、加号、减号和 /")
+	}
+	segments := strings.Split(path, "/")
+	if len(segments) > maxFilePathSegment {
+		return "", fmt.Errorf("文件路径最多 %d 层", maxFilePathSegment)
+	}
+	for _, seg := range segments {
+		if strings.HasPrefix(seg, ".") {
+			return "", errors.New("文件名不能以 . 开头")
+		}
+	}
+	// 运行目录中的编译产物
+	if segments[0] == executableName {
+		return "", fmt.Errorf("文件名 %s 已被保留", executableName)
+	}
+	// 隔离模式下运行目录即沙箱的根目录，系统目录和设备挂载在其中
+	for _, name := range sandboxMountPoints() {
+		if segments[0] == name {
+			return "", fmt.Errorf("文件名 %s 已被沙箱的系统目录占用", name)
+		}
+	}
+	return path, nil
+}
+
+// pathConflict 新路径是否与已有文件冲突（同名，或一方是另一方的目录）
+func pathConflict(path, existing string) bool {
+	return path == existing ||
+		strings.HasPrefix(existing, path+"/") ||
+		strings.HasPrefix(path, existing+"/")
+}
+
+// SourceFiles 工作区中主文件以外的文件（编译时与主文件一同写入运行目录）
+func SourceFiles(state models.CodeState) []models.CodeFile {
+	files := make([]models.CodeFile, 0, len(state.Files))
+	for _, f := range state.Files {
+		if f.Path != state.MainFile {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// WithFiles 返回附带工作区中其他文件的工具链副本：这些文件与主文件一同写入运行目录，
+// 其中与主文件同类的源文件作为额外的编译单元加入编译命令
+func (l *Language) WithFiles(files []models.CodeFile) *Language {
+	lang := *l
+	lang.Files = files
+	return &lang
+}
+
+// isUserSource 位置是否属于用户代码（主文件或工作区中的其他文件）
+func (l *Language) isUserSource(path string) bool {
+	path = strings.TrimPrefix(path, "./")
+	if path == l.SourceFile {
+		return true
+	}
+	for _, f := range l.Files {
+		if f.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// translationUnits 需要与主文件一同编译的其他源文件
+func (l *Language) translationUnits() []string {
+	exts := translationUnitExts[l.Extension()]
+	if exts == nil {
+		exts = []string{l.Extension()}
+	}
+	var units []string
+	for _, f := range l.Files {
+		for _, ext := range exts {
+			if filepath.Ext(f.Path) == ext {
+				units = append(units, f.Path)
+				break
+			}
+		}
+	}
+	return units
+}
+
+// compileArgs 展开后的编译命令，其他编译单元紧跟在主文件之后
+func (l *Language) compileArgs() []string {
+	return l.expandCommand(l.withTranslationUnits(l.CompileCommand))
+}
+
+// diagnosticsArgs 展开后的诊断命令，与编译命令一样检查所有编译单元
+func (l *Language) diagnosticsArgs() []string {
+	return l.expandCommand(l.withTranslationUnits(l.DiagnosticsCommand))
+}
+
+// withTranslationUnits 在命令中的 {src} 之后加入其他编译单元
+func (l *Language) withTranslationUnits(command []string) []string {
+	units := l.translationUnits()
+	if len(units) == 0 {
+		return command
+	}
+	for i, arg := range command {
+		if arg == placeholderSrc {
+			return append(append(append([]string{}, command[:i+1]...), units...), command[i+1:]...)
+		}
+	}
+	return command
+}
+
+// writeSources 将主文件和工作区中的其他文件写入目录
+func writeSources(dir string, lang *Language, code string) error {
+	if err := os.WriteFile(filepath.Join(dir, lang.SourceFile), []byte(code), 0644); err != nil {
+		return err
+	}
+	for _, f := range lang.Files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}